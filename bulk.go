@@ -0,0 +1,165 @@
+package sqlagent
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// Max number of placeholders in one statement of driver.
+const (
+	mysqlMaxPlaceholders    = 65535
+	postgresMaxPlaceholders = 65535
+	// sqliteMaxPlaceholders is SQLITE_MAX_VARIABLE_NUMBER before sqlite 3.32.0, it is 32766 since 3.32.0.
+	sqliteMaxPlaceholders = 999
+)
+
+// maxPlaceholders return max number of placeholders in one statement of dbType.
+func maxPlaceholders(dbType string) int {
+	switch dbType {
+	case dsncfg.Postgresql:
+		return postgresMaxPlaceholders
+	case dsncfg.Sqlite:
+		return sqliteMaxPlaceholders
+	}
+	return mysqlMaxPlaceholders
+}
+
+// SetMaxPlaceholders set max number of placeholders in one statement built by InsertModelsBuilders.
+// Default is 65535 for mysql and postgres, 999 for sqlite, set 32766 for sqlite 3.32.0 or later.
+func (a *SqlAgent) SetMaxPlaceholders(n int) {
+	a.maxPlaceholders = n
+}
+
+// InsertModelsBuilders use name and value of models feild to build multi-row insert sql.
+// models should be slice of struct or pointer to struct.
+// Rows are split into chunks so that number of placeholders in each sql does not exceed driver limit.
+// Field tagged with "autoincr" option is skipped if it is zero value in all models.
+// Field tagged with "created" or "updated" option is set to current time if it is zero value.
+// ignoreColumns should be the same with column name that is converted by sqlx.DB.Mapper.
+func (a *SqlAgent) InsertModelsBuilders(into string, models interface{}, ignoreColumns ...string) ([]sq.InsertBuilder, error) {
+	v := reflect.ValueOf(models)
+	if v.Kind() != reflect.Slice {
+		return nil, errorWrongArgs
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	t := reflectx.Deref(v.Type().Elem())
+	if t.Kind() != reflect.Struct {
+		return nil, errorWrongModel
+	}
+	rows := make([]reflect.Value, v.Len())
+	for i := range rows {
+		rows[i] = reflect.Indirect(v.Index(i))
+	}
+
+	var fields []*reflectx.FieldInfo
+	var columnNames []string
+	for _, fi := range a.db.Mapper.TypeMap(t).Index {
+		if isIgnoreFields(fi.Name, ignoreColumns) || !isColumnField(fi) {
+			continue
+		}
+		if isAutoIncrField(fi) && allZero(rows, fi.Index) {
+			continue
+		}
+		fields = append(fields, fi)
+		columnNames = append(columnNames, fi.Name)
+	}
+	if len(columnNames) == 0 {
+		return nil, errorWrongModel
+	}
+
+	limit := a.maxPlaceholders
+	if limit <= 0 {
+		limit = maxPlaceholders(a.dbType)
+	}
+	chunkSize := limit / len(columnNames)
+	if chunkSize == 0 {
+		return nil, errorWrongArgs
+	}
+
+	var builders []sq.InsertBuilder
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		builder := a.builder.Insert(into).Columns(columnNames...)
+		for _, row := range rows[start:end] {
+			params := make([]interface{}, len(fields))
+			for i, fi := range fields {
+				field := reflectx.FieldByIndexesReadOnly(row, fi.Index)
+				if isTimestampField(fi) && field.IsZero() {
+					params[i] = a.fillTimestamp(field)
+					continue
+				}
+				params[i] = field.Interface()
+			}
+			builder = builder.Values(params...)
+		}
+		builders = append(builders, builder)
+	}
+	return builders, nil
+}
+
+// InsertModels insert models by sql built by InsertModelsBuilders and return total rows affected.
+// Chunks are executed one by one, use InsertModelsTx to insert all chunks in one transaction.
+func (a *SqlAgent) InsertModels(ctx context.Context, into string, models interface{}, ignoreColumns ...string) (int64, error) {
+	return a.insertModels(ctx, a.db, into, models, false, ignoreColumns...)
+}
+
+// InsertModelsTx insert models the same as InsertModels, but all chunks are inserted in one transaction,
+// so no row is inserted if any chunk fails. It runs in a savepoint if ctx carries transaction of SqlAgent.
+func (a *SqlAgent) InsertModelsTx(ctx context.Context, into string, models interface{}, ignoreColumns ...string) (int64, error) {
+	var total int64
+	err := a.TransactionContext(ctx, nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		n, err := a.insertModels(ctx, tx, into, models, true, ignoreColumns...)
+		total = n
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (a *SqlAgent) insertModels(ctx context.Context, ext sqlx.ExecerContext, into string, models interface{}, inTx bool,
+	ignoreColumns ...string) (int64, error) {
+	builders, err := a.InsertModelsBuilders(into, models, ignoreColumns...)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, builder := range builders {
+		res, err := a.execContext(ctx, ext, builder, inTx)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// isColumnField return whether field is mapped to column the same as InsertModelBuilder,
+// children of struct field such as sql.NullString are not column.
+func isColumnField(fi *reflectx.FieldInfo) bool {
+	return fi.Name != "" && !fi.Embedded && fi.Name == fi.Path
+}
+
+func allZero(rows []reflect.Value, index []int) bool {
+	for _, row := range rows {
+		if !reflectx.FieldByIndexesReadOnly(row, index).IsZero() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type bulkModel struct {
+	ID   int64          `db:"id,pk,autoincr"`
+	Name string         `db:"name"`
+	Note sql.NullString `db:"note"`
+}
+
+func TestSqlAgent_InsertModelsBuilders(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "postgres"), dsncfg.Postgresql)
+	a.SetMaxPlaceholders(5)
+
+	models := []*bulkModel{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	builders, err := a.InsertModelsBuilders("user", models)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(builders))
+	sqlStr, args, err := builders[0].ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (name,note) VALUES ($1,$2),($3,$4)", sqlStr)
+	assert.Equal(t, []interface{}{"a", sql.NullString{}, "b", sql.NullString{}}, args)
+	sqlStr, _, err = builders[1].ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (name,note) VALUES ($1,$2)", sqlStr)
+
+	builders, err = a.InsertModelsBuilders("user", []bulkModel{{ID: 1, Name: "a"}, {Name: "b"}}, "note")
+	assert.Nil(t, err)
+	sqlStr, args, err = builders[0].ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (id,name) VALUES ($1,$2),($3,$4)", sqlStr)
+	assert.Equal(t, []interface{}{int64(1), "a", int64(0), "b"}, args)
+
+	builders, err = a.InsertModelsBuilders("user", []bulkModel{})
+	assert.Nil(t, err)
+	assert.Nil(t, builders)
+	_, err = a.InsertModelsBuilders("user", bulkModel{})
+	assert.Equal(t, errorWrongArgs, err)
+	_, err = a.InsertModelsBuilders("user", []int{1})
+	assert.Equal(t, errorWrongModel, err)
+}
+
+func TestMaxPlaceholders(t *testing.T) {
+	assert.Equal(t, 65535, maxPlaceholders(dsncfg.MySql))
+	assert.Equal(t, 65535, maxPlaceholders(dsncfg.Postgresql))
+	assert.Equal(t, 999, maxPlaceholders(dsncfg.Sqlite))
+}
+
+func TestSqlAgent_InsertModels(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	a.SetMaxPlaceholders(4)
+	execer := &fakeExecer{rowsAffected: 2}
+
+	models := []bulkModel{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+	n, err := a.insertModels(context.TODO(), execer, "user", models, false)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), n)
+}
+
+func TestSqlAgent_InsertModelsTx(t *testing.T) {
+	d, db := newFakeDB("mysql")
+	a := newSqlAgent(db, dsncfg.MySql)
+	defer a.Close()
+	// one row in each chunk
+	a.SetMaxPlaceholders(2)
+	models := []bulkModel{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	n, err := a.InsertModelsTx(context.TODO(), "user", models)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, int64(1), d.commits)
+
+	chunkErr := errors.New("insert chunk failed")
+	var inserts int64
+	d.execErr = func(query string) error {
+		if inserts++; inserts == 2 {
+			return chunkErr
+		}
+		return nil
+	}
+	n, err = a.InsertModelsTx(context.TODO(), "user", models)
+	assert.True(t, errors.Is(err, chunkErr))
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, int64(2), inserts)
+	assert.Equal(t, int64(1), d.commits)
+	assert.Equal(t, int64(1), d.rollbacks)
+}
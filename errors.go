@@ -0,0 +1,144 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Errors returned by SqlAgent, use errors.Is to check them.
+// Errors of query wrap original driver error, which can be got by errors.As or errors.Unwrap.
+var (
+	// ErrNotFound is returned by GetContext if no row is found, it wraps sql.ErrNoRows.
+	ErrNotFound = errors.New("record not found error")
+	// ErrDuplicateKey is returned if unique or primary key constraint is violated.
+	ErrDuplicateKey = errors.New("duplicate key error")
+	// ErrForeignKeyViolation is returned if foreign key constraint is violated.
+	ErrForeignKeyViolation = errors.New("foreign key violation error")
+	// ErrDeadlock is returned if transaction is aborted by deadlock.
+	ErrDeadlock = errors.New("deadlock error")
+	// ErrConnection is returned if connection to database is broken or can't be established.
+	ErrConnection = errors.New("database connection error")
+	// ErrConfigNotFound is returned if database config file is not found.
+	ErrConfigNotFound = errors.New("not found database config error")
+	// ErrWrongConfig is returned if database config is invalid.
+	ErrWrongConfig = errors.New("database config error")
+	// ErrStaleObject is returned by UpdateModel if model with version column is modified by others.
+	ErrStaleObject = errors.New("stale object error")
+)
+
+const (
+	mysqlErrDupEntry          = 1062
+	mysqlErrRowIsReferenced   = 1451
+	mysqlErrNoReferencedRow   = 1452
+	mysqlErrRowIsReferenced2  = 1216
+	mysqlErrNoReferencedRow2  = 1217
+	pgErrUniqueViolation      = "23505"
+	pgErrForeignKeyViolation  = "23503"
+	pgErrConnectionExceptions = "08"
+)
+
+// QueryError is error of query, it attaches the failed sql to original driver error.
+type QueryError struct {
+	// SQL is the failed sql, it is empty if error occurs when connecting or beginning transaction.
+	SQL string
+	// Err is original driver error.
+	Err error
+	// kind is sentinel error of Err, nil if Err is not classified.
+	kind error
+}
+
+func (e *QueryError) Error() string {
+	msg := e.Err.Error()
+	if e.kind != nil {
+		msg = fmt.Sprintf("%v: %s", e.kind, msg)
+	}
+	if e.SQL != "" {
+		msg = fmt.Sprintf("%s, sql: %s", msg, e.SQL)
+	}
+	return msg
+}
+
+// Unwrap return original driver error.
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Is report whether err is sentinel error of QueryError, such as ErrDuplicateKey.
+func (e *QueryError) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+// wrapQueryError wrap driver error of dbType with sql.
+func wrapQueryError(dbType, sqlStr string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		return err
+	}
+	return &QueryError{SQL: sqlStr, Err: err, kind: classifyError(dbType, err)}
+}
+
+// classifyError return sentinel error of driver error of dbType, nil if not classified.
+// Context errors are not classified, context.DeadlineExceeded is not ErrConnection though it is net.Error.
+func classifyError(dbType string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return ErrConnection
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrConnection
+	}
+
+	switch dbType {
+	case dsncfg.MySql:
+		var myErr *mysql.MySQLError
+		if !errors.As(err, &myErr) {
+			return nil
+		}
+		switch myErr.Number {
+		case mysqlErrDupEntry:
+			return ErrDuplicateKey
+		case mysqlErrRowIsReferenced, mysqlErrNoReferencedRow, mysqlErrRowIsReferenced2, mysqlErrNoReferencedRow2:
+			return ErrForeignKeyViolation
+		case mysqlErrDeadlock:
+			return ErrDeadlock
+		}
+	case dsncfg.Postgresql:
+		code := pgErrorCode(err)
+		switch {
+		case code == pgErrUniqueViolation:
+			return ErrDuplicateKey
+		case code == pgErrForeignKeyViolation:
+			return ErrForeignKeyViolation
+		case code == pgErrDeadlockDetected:
+			return ErrDeadlock
+		case strings.HasPrefix(code, pgErrConnectionExceptions):
+			return ErrConnection
+		}
+	case dsncfg.Sqlite:
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "UNIQUE constraint failed"), strings.Contains(msg, "PRIMARY KEY must be unique"):
+			return ErrDuplicateKey
+		case strings.Contains(msg, "FOREIGN KEY constraint failed"):
+			return ErrForeignKeyViolation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapQueryError(t *testing.T) {
+	cases := []struct {
+		dbType string
+		err    error
+		expect error
+	}{
+		{dsncfg.MySql, sql.ErrNoRows, ErrNotFound},
+		{dsncfg.MySql, driver.ErrBadConn, ErrConnection},
+		{dsncfg.MySql, &mysql.MySQLError{Number: 1062}, ErrDuplicateKey},
+		{dsncfg.MySql, &mysql.MySQLError{Number: 1452}, ErrForeignKeyViolation},
+		{dsncfg.MySql, &mysql.MySQLError{Number: 1213}, ErrDeadlock},
+		{dsncfg.Postgresql, &pqError{Code: "23505"}, ErrDuplicateKey},
+		{dsncfg.Postgresql, &pqError{Code: "23503"}, ErrForeignKeyViolation},
+		{dsncfg.Postgresql, &pqError{Code: "40P01"}, ErrDeadlock},
+		{dsncfg.Postgresql, &pqError{Code: "08006"}, ErrConnection},
+		{dsncfg.Sqlite, errors.New("UNIQUE constraint failed: user.name"), ErrDuplicateKey},
+		{dsncfg.Sqlite, errors.New("FOREIGN KEY constraint failed"), ErrForeignKeyViolation},
+	}
+	for _, c := range cases {
+		err := wrapQueryError(c.dbType, "SELECT 1", c.err)
+		assert.True(t, errors.Is(err, c.expect), "%v", c.err)
+		assert.True(t, errors.Is(err, c.err))
+		assert.Contains(t, err.Error(), "sql: SELECT 1")
+	}
+
+	myErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	err := wrapQueryError(dsncfg.MySql, "INSERT INTO user (id) VALUES (?)", myErr)
+	var target *mysql.MySQLError
+	assert.True(t, errors.As(err, &target))
+	assert.True(t, myErr == target)
+	assert.False(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, "duplicate key error: Error 1062: Duplicate entry, sql: INSERT INTO user (id) VALUES (?)", err.Error())
+
+	wrapped := fmt.Errorf("insert user: %w", err)
+	assert.True(t, err == wrapQueryError(dsncfg.MySql, "SELECT 1", err))
+	assert.True(t, errors.Is(wrapped, ErrDuplicateKey))
+
+	assert.Nil(t, wrapQueryError(dsncfg.MySql, "SELECT 1", nil))
+	err = wrapQueryError(dsncfg.MySql, "", driver.ErrBadConn)
+	assert.Equal(t, "database connection error: driver: bad connection", err.Error())
+
+	for _, ctxErr := range []error{context.DeadlineExceeded, context.Canceled} {
+		err = wrapQueryError(dsncfg.MySql, "SELECT 1", fmt.Errorf("query: %w", ctxErr))
+		assert.True(t, errors.Is(err, ctxErr))
+		assert.False(t, errors.Is(err, ErrConnection))
+		assert.Equal(t, "query: "+ctxErr.Error()+", sql: SELECT 1", err.Error())
+	}
+}
+
+func TestSqlAgent_GetContextNotFound(t *testing.T) {
+	d, db := newFakeDB("mysql")
+	d.columns = []string{"id"}
+	sa := newSqlAgent(db, dsncfg.MySql)
+	defer sa.Close()
+	ctx := context.TODO()
+	builder := sa.SelectBuilder("id").From("user").Where("id = ?", 1)
+
+	var id int64
+	err := sa.GetContext(ctx, builder, &id)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.False(t, err == sql.ErrNoRows)
+
+	err = sa.TransactionContext(ctx, nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		return TxGetContext(ctx, tx, builder, &id)
+	})
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestSqlAgent_CommitError(t *testing.T) {
+	d, db := newFakeDB("mysql")
+	d.commitErr = driver.ErrBadConn
+	sa := newSqlAgent(db, dsncfg.MySql)
+	defer sa.Close()
+
+	err := sa.TransactionContext(context.TODO(), nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		return nil
+	})
+	var queryErr *QueryError
+	assert.True(t, errors.As(err, &queryErr))
+	assert.Equal(t, "", queryErr.SQL)
+	assert.True(t, errors.Is(err, ErrConnection))
+	assert.Equal(t, int64(1), d.commits)
+}
@@ -0,0 +1,204 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// Executor run sql built by squirrel builder and build sql from model.
+// It is implemented by *SqlAgent and *Tx, so that the same code can run inside or outside transaction.
+type Executor interface {
+	ExecContext(ctx context.Context, builder sq.Sqlizer) (sql.Result, error)
+	GetContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error
+	SelectContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error
+	QueryContext(ctx context.Context, builder sq.Sqlizer) (*Rows, error)
+	Count(ctx context.Context, selectBuilder sq.SelectBuilder) (uint64, error)
+	Exists(ctx context.Context, selectBuilder sq.SelectBuilder) (bool, error)
+	Pluck(ctx context.Context, selectBuilder sq.SelectBuilder, column string, dest interface{}) error
+	SelectMaps(ctx context.Context, builder sq.Sqlizer) ([]map[string]interface{}, error)
+	Paginate(ctx context.Context, selectBuilder sq.SelectBuilder, page, size uint64, dest interface{}) (Page, error)
+	KeysetPage(ctx context.Context, builder sq.SelectBuilder, keys []string, cursor string, size uint64,
+		dest interface{}) (string, error)
+	InsertModel(ctx context.Context, into string, model interface{}, ignoreColumns ...string) (sql.Result, error)
+	InsertModels(ctx context.Context, into string, models interface{}, ignoreColumns ...string) (int64, error)
+	UpdateModel(ctx context.Context, updateBuilder sq.UpdateBuilder, model interface{}, opts ...UpdateOption) (sql.Result, error)
+
+	InsertBuilder(into string) sq.InsertBuilder
+	UpdateBuilder(table string) sq.UpdateBuilder
+	DeleteBuilder(table string) sq.DeleteBuilder
+	SelectBuilder(columns ...string) sq.SelectBuilder
+	InsertModelBuilder(into string, model interface{}, ignoreColumns ...string) sq.InsertBuilder
+	UpsertModelBuilder(into string, model interface{}, conflictColumns, insertOnlyColumns []string,
+		ignoreColumns ...string) (sq.InsertBuilder, error)
+	SetUpdateColumns(updateBuilder sq.UpdateBuilder, model interface{}, ignoreColumns ...string) sq.UpdateBuilder
+	SetUpdateColumnsWith(updateBuilder sq.UpdateBuilder, model interface{}, opts ...UpdateOption) sq.UpdateBuilder
+	ModelColumns(model interface{}, ignoreColumns ...string) []string
+	Mapper() *reflectx.Mapper
+}
+
+var (
+	_ Executor = (*SqlAgent)(nil)
+	_ Executor = (*Tx)(nil)
+	_ clocker  = (*SqlAgent)(nil)
+	_ clocker  = (*Tx)(nil)
+)
+
+// Tx is transaction of SqlAgent which implements Executor.
+// Hooks of SqlAgent are called and builder helpers of SqlAgent are used.
+type Tx struct {
+	agent *SqlAgent
+	tx    *sqlx.Tx
+}
+
+// WrapTx return Tx of sqlx.Tx, tx is usually passed to Transaction func.
+func (a *SqlAgent) WrapTx(tx *sqlx.Tx) *Tx {
+	return &Tx{agent: a, tx: tx}
+}
+
+// Executor return Tx if ctx carries transaction of SqlAgent, otherwise return SqlAgent itself.
+// ctx carries transaction in func passed to TransactionContext.
+func (a *SqlAgent) Executor(ctx context.Context) Executor {
+	if state := txStateFromContext(ctx); state != nil && state.agent == a {
+		return a.WrapTx(state.tx)
+	}
+	return a
+}
+
+// Tx return the wrapped sqlx.Tx.
+func (t *Tx) Tx() *sqlx.Tx {
+	return t.tx
+}
+
+// Agent return SqlAgent of transaction.
+func (t *Tx) Agent() *SqlAgent {
+	return t.agent
+}
+
+// AfterCommit register fn to be called after transaction is committed, see AfterCommit.
+func (t *Tx) AfterCommit(fn func()) error {
+	return AfterCommit(t.tx, fn)
+}
+
+// AfterRollback register fn to be called after transaction is rolled back, see AfterRollback.
+func (t *Tx) AfterRollback(fn func()) error {
+	return AfterRollback(t.tx, fn)
+}
+
+// ExecContext exec sql built by sq.InsertBuilder/sq.UpdateBuilder/sq.DeleteBuilder in transaction and return result.
+func (t *Tx) ExecContext(ctx context.Context, builder sq.Sqlizer) (sql.Result, error) {
+	return t.agent.execContext(ctx, t.tx, builder, true)
+}
+
+// GetContext get one record in transaction by sql built by sq.SelectBuilder and scan to dest.
+// Use errors.Is(err, ErrNotFound) to check no record is found, see SqlAgent.GetContext.
+func (t *Tx) GetContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
+	return t.agent.getContext(ctx, t.tx, builder, dest, true)
+}
+
+// SelectContext get one or multi records in transaction by sql built by sq.SelectBuilder and scan to dest.
+func (t *Tx) SelectContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
+	return t.agent.selectContext(ctx, t.tx, builder, dest, true)
+}
+
+// QueryContext query records in transaction and return iterator of rows.
+func (t *Tx) QueryContext(ctx context.Context, builder sq.Sqlizer) (*Rows, error) {
+	return t.agent.queryContext(ctx, t.tx, builder, true)
+}
+
+// Count return number of rows selected by builder in transaction.
+func (t *Tx) Count(ctx context.Context, selectBuilder sq.SelectBuilder) (uint64, error) {
+	return count(ctx, t, selectBuilder)
+}
+
+// Exists return whether builder selects any row in transaction.
+func (t *Tx) Exists(ctx context.Context, selectBuilder sq.SelectBuilder) (bool, error) {
+	return exists(ctx, t, selectBuilder)
+}
+
+// Pluck select one column of rows selected by builder to dest in transaction.
+func (t *Tx) Pluck(ctx context.Context, selectBuilder sq.SelectBuilder, column string, dest interface{}) error {
+	return pluck(ctx, t, selectBuilder, column, dest)
+}
+
+// SelectMaps select rows as maps of column name to value in transaction.
+func (t *Tx) SelectMaps(ctx context.Context, builder sq.Sqlizer) ([]map[string]interface{}, error) {
+	return selectMaps(ctx, t, builder)
+}
+
+// Paginate select rows of page and count rows of all pages in transaction, see SqlAgent.Paginate.
+func (t *Tx) Paginate(ctx context.Context, selectBuilder sq.SelectBuilder, page, size uint64,
+	dest interface{}) (Page, error) {
+	return paginate(ctx, t, selectBuilder, page, size, dest)
+}
+
+// KeysetPage select one page of rows after cursor in transaction, see SqlAgent.KeysetPage.
+func (t *Tx) KeysetPage(ctx context.Context, builder sq.SelectBuilder, keys []string, cursor string, size uint64,
+	dest interface{}) (string, error) {
+	return keysetPage(ctx, t, t.agent.dbType, builder, keys, cursor, size, dest)
+}
+
+// InsertModel insert model in transaction and set generated id to field tagged with "autoincr".
+func (t *Tx) InsertModel(ctx context.Context, into string, model interface{}, ignoreColumns ...string) (sql.Result, error) {
+	return t.agent.insertModel(ctx, t.tx, into, model, true, ignoreColumns...)
+}
+
+// InsertModels insert models in transaction and return total rows affected, all chunks are inserted in the transaction.
+func (t *Tx) InsertModels(ctx context.Context, into string, models interface{}, ignoreColumns ...string) (int64, error) {
+	return t.agent.insertModels(ctx, t.tx, into, models, true, ignoreColumns...)
+}
+
+// UpdateModel update model in transaction, see SqlAgent.UpdateModel.
+func (t *Tx) UpdateModel(ctx context.Context, updateBuilder sq.UpdateBuilder, model interface{},
+	opts ...UpdateOption) (sql.Result, error) {
+	return t.agent.updateModel(ctx, t.tx, updateBuilder, model, true, opts...)
+}
+
+func (t *Tx) InsertBuilder(into string) sq.InsertBuilder {
+	return t.agent.InsertBuilder(into)
+}
+
+func (t *Tx) UpdateBuilder(table string) sq.UpdateBuilder {
+	return t.agent.UpdateBuilder(table)
+}
+
+func (t *Tx) DeleteBuilder(table string) sq.DeleteBuilder {
+	return t.agent.DeleteBuilder(table)
+}
+
+func (t *Tx) SelectBuilder(columns ...string) sq.SelectBuilder {
+	return t.agent.SelectBuilder(columns...)
+}
+
+func (t *Tx) InsertModelBuilder(into string, model interface{}, ignoreColumns ...string) sq.InsertBuilder {
+	return t.agent.InsertModelBuilder(into, model, ignoreColumns...)
+}
+
+func (t *Tx) UpsertModelBuilder(into string, model interface{}, conflictColumns, insertOnlyColumns []string,
+	ignoreColumns ...string) (sq.InsertBuilder, error) {
+	return t.agent.UpsertModelBuilder(into, model, conflictColumns, insertOnlyColumns, ignoreColumns...)
+}
+
+func (t *Tx) SetUpdateColumns(updateBuilder sq.UpdateBuilder, model interface{}, ignoreColumns ...string) sq.UpdateBuilder {
+	return t.agent.SetUpdateColumns(updateBuilder, model, ignoreColumns...)
+}
+
+func (t *Tx) SetUpdateColumnsWith(updateBuilder sq.UpdateBuilder, model interface{}, opts ...UpdateOption) sq.UpdateBuilder {
+	return t.agent.SetUpdateColumnsWith(updateBuilder, model, opts...)
+}
+
+func (t *Tx) ModelColumns(model interface{}, ignoreColumns ...string) []string {
+	return t.agent.ModelColumns(model, ignoreColumns...)
+}
+
+func (t *Tx) Mapper() *reflectx.Mapper {
+	return t.agent.Mapper()
+}
+
+func (t *Tx) timestamp() time.Time {
+	return t.agent.timestamp()
+}
@@ -0,0 +1,38 @@
+package sqlagent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqlAgent_Executor(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	assert.True(t, a == a.Executor(context.TODO()))
+
+	tx := &sqlx.Tx{}
+	ctx := contextWithTxState(context.TODO(), &txState{agent: a, tx: tx})
+	ex, ok := a.Executor(ctx).(*Tx)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.True(t, tx == ex.Tx())
+	assert.True(t, a == ex.Agent())
+
+	// transaction of other SqlAgent is not used
+	other := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	assert.True(t, other == other.Executor(ctx))
+
+	type model struct {
+		ID   int64
+		Name string
+	}
+	sqlStr, args, err := ex.InsertModelBuilder("user", model{Name: "a"}, "id").ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (name) VALUES (?)", sqlStr)
+	assert.Equal(t, []interface{}{"a"}, args)
+	assert.Equal(t, a.ModelColumns(model{}), ex.ModelColumns(model{}))
+}
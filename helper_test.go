@@ -0,0 +1,183 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// fakeQuery is sql and args recorded by fakeExecutor.
+type fakeQuery struct {
+	SQL  string
+	Args []interface{}
+}
+
+// fakeExecutor is Executor which records sql instead of running it.
+// ExecContext returns rowsAffected, GetContext and SelectContext set getResult and selectResult to dest.
+type fakeExecutor struct {
+	*SqlAgent
+	queries      []fakeQuery
+	rowsAffected int64
+	getResult    interface{}
+	selectResult interface{}
+}
+
+func newFakeExecutor(dbType string) *fakeExecutor {
+	drivers := map[string]string{dsncfg.MySql: "mysql", dsncfg.Postgresql: "postgres", dsncfg.Sqlite: "sqlite3"}
+	return &fakeExecutor{SqlAgent: newSqlAgent(sqlx.NewDb(nil, drivers[dbType]), dbType)}
+}
+
+func (e *fakeExecutor) record(builder sq.Sqlizer) error {
+	sqlStr, args, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+	e.queries = append(e.queries, fakeQuery{SQL: sqlStr, Args: args})
+	return nil
+}
+
+func (e *fakeExecutor) ExecContext(ctx context.Context, builder sq.Sqlizer) (sql.Result, error) {
+	if err := e.record(builder); err != nil {
+		return nil, err
+	}
+	return sqlResult(e.rowsAffected), nil
+}
+
+// UpdateModel records sql built by SetUpdateColumnsWith, version column is not checked.
+func (e *fakeExecutor) UpdateModel(ctx context.Context, updateBuilder sq.UpdateBuilder, model interface{},
+	opts ...UpdateOption) (sql.Result, error) {
+	return e.ExecContext(ctx, e.SetUpdateColumnsWith(updateBuilder, model, opts...))
+}
+
+func (e *fakeExecutor) GetContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
+	if err := e.record(builder); err != nil {
+		return err
+	}
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(e.getResult))
+	return nil
+}
+
+func (e *fakeExecutor) SelectContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
+	if err := e.record(builder); err != nil {
+		return err
+	}
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(e.selectResult))
+	return nil
+}
+
+// fakeDriver is database driver which counts prepared and closed statements.
+// Exec of statement returns execErr(query) if execErr is set, Query returns rows, Commit returns commitErr.
+type fakeDriver struct {
+	prepared  int64
+	closed    int64
+	commits   int64
+	rollbacks int64
+	execErr   func(query string) error
+	commitErr error
+	// columns and rows are returned by Query of every statement.
+	columns []string
+	rows    [][]driver.Value
+}
+
+var fakeDriverSeq int64
+
+// newFakeDB return sqlx.DB of a new fakeDriver, driverName is the name seen by SqlAgent such as "mysql".
+func newFakeDB(driverName string) (*fakeDriver, *sqlx.DB) {
+	d := &fakeDriver{}
+	name := fmt.Sprintf("sqlagent_fake_%d", atomic.AddInt64(&fakeDriverSeq, 1))
+	sql.Register(name, d)
+	db, _ := sql.Open(name, "")
+	return d, sqlx.NewDb(db, driverName)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt64(&c.d.prepared, 1)
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	atomic.AddInt64(&c.d.commits, 1)
+	return c.d.commitErr
+}
+
+func (c *fakeConn) Rollback() error {
+	atomic.AddInt64(&c.d.rollbacks, 1)
+	return nil
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+	once  sync.Once
+}
+
+func (s *fakeStmt) Close() error {
+	s.once.Do(func() {
+		atomic.AddInt64(&s.d.closed, 1)
+	})
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.d.execErr != nil {
+		if err := s.d.execErr(s.query); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{columns: s.d.columns, rows: s.d.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,163 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// QueryEvent describe one sql execution passed to Hook.
+type QueryEvent struct {
+	// Builder is the squirrel builder passed to ExecContext/GetContext/SelectContext.
+	Builder sq.Sqlizer
+	SQL     string
+	Args    []interface{}
+	// InTx is true if sql runs in transaction.
+	InTx  bool
+	Start time.Time
+	// Duration and fields below are set before Hook.After is called.
+	Duration time.Duration
+	// RowsAffected is rows affected by exec or rows scanned by query.
+	RowsAffected int64
+	Err          error
+}
+
+// Hook is called around every sql execution of SqlAgent and transaction helpers.
+// Before can return a new context which is used by the execution and After, e.g. to carry a trace span.
+// Hooks are called in the order added and After is called in reverse order.
+type Hook interface {
+	Before(ctx context.Context, event *QueryEvent) context.Context
+	After(ctx context.Context, event *QueryEvent)
+}
+
+// TxEvent describe one finished transaction passed to TxHook.
+type TxEvent struct {
+	// Committed is true if transaction is committed, otherwise it is rolled back.
+	Committed bool
+	Duration  time.Duration
+	// Err is error returned by transaction func or commit.
+	Err error
+}
+
+// TxHook is an optional interface of Hook, AfterTx is called after SqlAgent.Transaction commit or roll back.
+type TxHook interface {
+	AfterTx(ctx context.Context, event *TxEvent)
+}
+
+// AddHook add hooks to SqlAgent, should be called before SqlAgent is used.
+func (a *SqlAgent) AddHook(hooks ...Hook) {
+	newHooks := make([]Hook, 0, len(a.hooks)+len(hooks))
+	newHooks = append(newHooks, a.hooks...)
+	a.hooks = append(newHooks, hooks...)
+}
+
+// runHooks build sql and call fn between hooks. a can be nil, then no hook is called.
+func (a *SqlAgent) runHooks(ctx context.Context, builder sq.Sqlizer, inTx bool,
+	fn func(ctx context.Context, event *QueryEvent) error) error {
+	sqlStr, args, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+	var hooks []Hook
+	if a != nil {
+		hooks = a.hooks
+	}
+	event := &QueryEvent{
+		Builder: builder,
+		SQL:     sqlStr,
+		Args:    args,
+		InTx:    inTx,
+	}
+	for _, h := range hooks {
+		ctx = h.Before(ctx, event)
+	}
+	event.Start = time.Now()
+	event.Err = fn(ctx, event)
+	event.Duration = time.Since(event.Start)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].After(ctx, event)
+	}
+	var dbType string
+	if a != nil {
+		dbType = a.dbType
+	}
+	return wrapQueryError(dbType, event.SQL, event.Err)
+}
+
+func (a *SqlAgent) afterTx(ctx context.Context, start time.Time, committed bool, err error) {
+	event := &TxEvent{
+		Committed: committed,
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+	for i := len(a.hooks) - 1; i >= 0; i-- {
+		if h, ok := a.hooks[i].(TxHook); ok {
+			h.AfterTx(ctx, event)
+		}
+	}
+}
+
+func (a *SqlAgent) execContext(ctx context.Context, execer sqlx.ExecerContext, builder sq.Sqlizer, inTx bool) (sql.Result, error) {
+	var res sql.Result
+	err := a.runHooks(ctx, builder, inTx, func(ctx context.Context, event *QueryEvent) (err error) {
+		stmt, release, err := a.cachedStmt(ctx, execer, event.SQL)
+		if err != nil {
+			return err
+		}
+		if stmt != nil {
+			defer release()
+			res, err = stmt.ExecContext(ctx, event.Args...)
+		} else {
+			res, err = execer.ExecContext(ctx, event.SQL, event.Args...)
+		}
+		if err == nil {
+			event.RowsAffected, _ = res.RowsAffected()
+		}
+		return
+	})
+	return res, err
+}
+
+func (a *SqlAgent) getContext(ctx context.Context, queryer sqlx.QueryerContext, builder sq.Sqlizer, dest interface{}, inTx bool) error {
+	return a.runHooks(ctx, builder, inTx, func(ctx context.Context, event *QueryEvent) error {
+		stmt, release, err := a.cachedStmt(ctx, queryer, event.SQL)
+		if err != nil {
+			return err
+		}
+		if stmt != nil {
+			defer release()
+			err = stmt.GetContext(ctx, dest, event.Args...)
+		} else {
+			err = sqlx.GetContext(ctx, queryer, dest, event.SQL, event.Args...)
+		}
+		if err == nil {
+			event.RowsAffected = 1
+		}
+		return err
+	})
+}
+
+func (a *SqlAgent) selectContext(ctx context.Context, queryer sqlx.QueryerContext, builder sq.Sqlizer, dest interface{}, inTx bool) error {
+	return a.runHooks(ctx, builder, inTx, func(ctx context.Context, event *QueryEvent) error {
+		stmt, release, err := a.cachedStmt(ctx, queryer, event.SQL)
+		if err != nil {
+			return err
+		}
+		if stmt != nil {
+			defer release()
+			err = stmt.SelectContext(ctx, dest, event.Args...)
+		} else {
+			err = sqlx.SelectContext(ctx, queryer, dest, event.SQL, event.Args...)
+		}
+		if err == nil {
+			if v := reflect.Indirect(reflect.ValueOf(dest)); v.Kind() == reflect.Slice {
+				event.RowsAffected = int64(v.Len())
+			}
+		}
+		return err
+	})
+}
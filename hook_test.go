@@ -0,0 +1,88 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+type hookCtxKey struct{}
+
+type recordHook struct {
+	name   string
+	calls  *[]string
+	events []*QueryEvent
+}
+
+func (h *recordHook) Before(ctx context.Context, event *QueryEvent) context.Context {
+	*h.calls = append(*h.calls, "before "+h.name)
+	return context.WithValue(ctx, hookCtxKey{}, h.name)
+}
+
+func (h *recordHook) After(ctx context.Context, event *QueryEvent) {
+	*h.calls = append(*h.calls, "after "+h.name)
+	h.events = append(h.events, event)
+}
+
+type fakeExecer struct {
+	ctx          context.Context
+	rowsAffected int64
+	err          error
+}
+
+func (e *fakeExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.ctx = ctx
+	if e.err != nil {
+		return nil, e.err
+	}
+	return sqlResult(e.rowsAffected), nil
+}
+
+type sqlResult int64
+
+func (r sqlResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (r sqlResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+func TestSqlAgent_Hook(t *testing.T) {
+	var calls []string
+	h1 := &recordHook{name: "h1", calls: &calls}
+	h2 := &recordHook{name: "h2", calls: &calls}
+	a := &SqlAgent{}
+	a.AddHook(h1, h2)
+
+	execer := &fakeExecer{rowsAffected: 2}
+	builder := sq.Update("user").Set("name", "a").Where(sq.Eq{"id": 1})
+	_, err := a.execContext(context.TODO(), execer, builder, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"before h1", "before h2", "after h2", "after h1"}, calls)
+	assert.Equal(t, "h2", execer.ctx.Value(hookCtxKey{}))
+
+	event := h1.events[0]
+	assert.True(t, event == h2.events[0])
+	assert.Equal(t, "UPDATE user SET name = ? WHERE id = ?", event.SQL)
+	assert.Equal(t, []interface{}{"a", 1}, event.Args)
+	assert.Equal(t, int64(2), event.RowsAffected)
+	assert.False(t, event.InTx)
+
+	execErr := errors.New("exec error")
+	execer.err = execErr
+	_, err = a.execContext(context.TODO(), execer, builder, true)
+	assert.True(t, errors.Is(err, execErr))
+	assert.Contains(t, err.Error(), "sql: UPDATE user SET name = ? WHERE id = ?")
+	assert.Equal(t, execErr, h1.events[1].Err)
+	assert.True(t, h1.events[1].InTx)
+
+	// build error does not call hooks
+	_, err = a.execContext(context.TODO(), execer, sq.Update("user"), false)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, len(h1.events))
+}
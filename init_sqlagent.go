@@ -21,22 +21,25 @@ const (
 )
 
 var (
+	// initMu guards initOnce which is reset by CloseAll.
+	initMu   sync.Mutex
 	initOnce = &sync.Once{}
 )
 
 // Init module SqlAgent with database config.
 func Init(cfg *dsncfg.Database) error {
-	return initSqlAgent(cfg)
+	return initSqlAgent(func() error {
+		return InitNamed(DefaultAgentName, cfg)
+	})
 }
 
 // Init module SqlAgent with database config.
 // cfgFile: config file path, support file type [.json | .yaml/.yml], default decoder is json.
+// Options in config file such as "slow_query" are applied to SqlAgent.
 func InitFromConfig(cfgFile string) error {
-	cfg, err := readDBConfig(cfgFile)
-	if err != nil {
-		return err
-	}
-	return initSqlAgent(cfg)
+	return initSqlAgent(func() error {
+		return InitNamedFromConfig(DefaultAgentName, cfgFile)
+	})
 }
 
 // InitFromEnv use Env variable to detect config file and init SqlAgent with first found config file.
@@ -50,7 +53,7 @@ func InitFromConfig(cfgFile string) error {
 func InitFromEnv() error {
 	cfgFile := detectDBConfig()
 	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
-		return errorNotFoundDBConfig
+		return ErrConfigNotFound
 	}
 	return InitFromConfig(cfgFile)
 }
@@ -65,15 +68,23 @@ func findInDir(dir, filePrefix string) (string, error) {
 			return match[0], nil
 		}
 	}
-	return "", errorNotFoundDBConfig
+	return "", ErrConfigNotFound
 }
 
-func findDBConfig(lvl int, subdir ...string) (cfgFile string) {
-	cfgFname := defaultDBConfigFileName
-	label := os.Getenv(envDBLabel)
-	if label != "" {
-		cfgFname = fmt.Sprintf("%s-%s", cfgFname, label)
+// dbConfigFileName return config file name without suffix.
+// Format is "database-$label" or "database" if label is empty.
+func dbConfigFileName(label string) string {
+	if label == "" {
+		return defaultDBConfigFileName
 	}
+	return fmt.Sprintf("%s-%s", defaultDBConfigFileName, label)
+}
+
+func findDBConfig(lvl int, subdir ...string) (cfgFile string) {
+	return findDBConfigFile(dbConfigFileName(os.Getenv(envDBLabel)), lvl, subdir...)
+}
+
+func findDBConfigFile(cfgFname string, lvl int, subdir ...string) (cfgFile string) {
 	curdir, err := os.Getwd()
 	if err != nil {
 		return
@@ -111,30 +122,64 @@ func detectDBConfig() string {
 
 // cfgFile: config file path, support file type [.json | .yaml/.yml], default decoder is json.
 func readDBConfig(cfgFile string) (*dsncfg.Database, error) {
+	dbCfg := &dsncfg.Database{}
+	if err := readConfigFile(cfgFile, dbCfg); err != nil {
+		return nil, err
+	}
+	return dbCfg, nil
+}
+
+// agentConfig is SqlAgent options in database config file.
+type agentConfig struct {
+	SlowQuery *SlowQueryConfig `json:"slow_query" yaml:"slow_query"`
+}
+
+// readAgentConfig read SqlAgent options from the same config file of readDBConfig.
+func readAgentConfig(cfgFile string) (*agentConfig, error) {
+	agentCfg := &agentConfig{}
+	if err := readConfigFile(cfgFile, agentCfg); err != nil {
+		return nil, err
+	}
+	return agentCfg, nil
+}
+
+// apply options in config file to SqlAgent.
+func (c *agentConfig) apply(agent *SqlAgent) error {
+	if c == nil {
+		return nil
+	}
+	if c.SlowQuery != nil {
+		if err := agent.EnableSlowQueryLog(c.SlowQuery, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cfgFile: config file path, support file type [.json | .yaml/.yml], default decoder is json.
+func readConfigFile(cfgFile string, v interface{}) error {
 	c, err := ioutil.ReadFile(cfgFile)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	ext := path.Ext(cfgFile)
 	ext = strings.ToLower(ext)
 
-	dbCfg := &dsncfg.Database{}
 	switch ext {
 	case ".yaml", ".yml":
-		err = yaml.Unmarshal(c, dbCfg)
+		return yaml.Unmarshal(c, v)
 	default:
 		// default: .json
-		err = json.Unmarshal(c, dbCfg)
+		return json.Unmarshal(c, v)
 	}
-	if err != nil {
-		return nil, err
-	}
-	return dbCfg, nil
 }
 
 func setDefaultDBParameters(cfg *dsncfg.Database) {
 	if cfg.Type == dsncfg.MySql {
+		if cfg.Parameters == nil {
+			cfg.Parameters = make(map[string]string)
+		}
 		defaultParams := map[string]string{
 			"parseTime":  "true",
 			"charset":    "utf8mb4,utf8",
@@ -149,11 +194,13 @@ func setDefaultDBParameters(cfg *dsncfg.Database) {
 	}
 }
 
-// initSqlAgent init module SqlAgent only once.
-func initSqlAgent(cfg *dsncfg.Database) (err error) {
-	initOnce.Do(func() {
-		setDefaultDBParameters(cfg)
-		defaultAgent, err = NewSqlAgent(cfg)
+// initSqlAgent init module SqlAgent only once with initFn.
+func initSqlAgent(initFn func() error) (err error) {
+	initMu.Lock()
+	once := initOnce
+	initMu.Unlock()
+	once.Do(func() {
+		err = initFn()
 	})
 	return
 }
@@ -71,7 +71,7 @@ func TestFindInDir(t *testing.T) {
 	}
 
 	found, err := findInDir(pwd, defaultDBConfigFileName)
-	if err != errorNotFoundDBConfig {
+	if err != ErrConfigNotFound {
 		t.Fatalf("findInDir return error: %v", err)
 	}
 	fpath := createTestConfig(pwd, defaultDBConfigFileName, t)
@@ -0,0 +1,65 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// insertResult is sql.Result of insert with RETURNING clause.
+type insertResult struct {
+	lastInsertID int64
+}
+
+func (r insertResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r insertResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+// InsertModel insert model built by InsertModelBuilder and set generated id to field tagged with "autoincr".
+// Generated id is got by LastInsertId for mysql and sqlite, by RETURNING clause for postgres.
+// model should be pointer to struct to get generated id.
+func (a *SqlAgent) InsertModel(ctx context.Context, into string, model interface{}, ignoreColumns ...string) (sql.Result, error) {
+	return a.insertModel(ctx, a.db, into, model, false, ignoreColumns...)
+}
+
+func (a *SqlAgent) insertModel(ctx context.Context, ext sqlx.ExtContext, into string, model interface{}, inTx bool,
+	ignoreColumns ...string) (sql.Result, error) {
+	builder := a.InsertModelBuilder(into, model, ignoreColumns...)
+
+	v := reflect.ValueOf(model)
+	fi := fieldWithOption(a.db.Mapper.TypeMap(v.Type()), tagOptionAutoIncr)
+	if fi == nil || v.Kind() != reflect.Ptr || isIgnoreFields(fi.Name, ignoreColumns) {
+		return a.execContext(ctx, ext, builder, inTx)
+	}
+	field := reflectx.FieldByIndexes(v.Elem(), fi.Index)
+	if !field.IsZero() {
+		return a.execContext(ctx, ext, builder, inTx)
+	}
+
+	if a.dbType == dsncfg.Postgresql {
+		var id int64
+		builder = builder.Suffix("RETURNING " + fi.Name)
+		if err := a.getContext(ctx, ext, builder, &id, inTx); err != nil {
+			return nil, err
+		}
+		return insertResult{lastInsertID: id}, setIntValue(field, id)
+	}
+
+	res, err := a.execContext(ctx, ext, builder, inTx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return res, err
+	}
+	return res, setIntValue(field, id)
+}
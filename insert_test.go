@@ -0,0 +1,39 @@
+package sqlagent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertModelBuilder_AutoIncr(t *testing.T) {
+	type model struct {
+		ID   uint64 `db:"id,pk,autoincr"`
+		Name string `db:"name"`
+	}
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+
+	sqlStr, args, err := a.InsertModelBuilder("user", &model{Name: "a"}).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (name) VALUES (?)", sqlStr)
+	assert.Equal(t, []interface{}{"a"}, args)
+
+	sqlStr, args, err = a.InsertModelBuilder("user", &model{ID: 3, Name: "a"}).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (id,name) VALUES (?,?)", sqlStr)
+	assert.Equal(t, []interface{}{uint64(3), "a"}, args)
+}
+
+func TestSetIntValue(t *testing.T) {
+	var i int32
+	var u uint
+	var s string
+	assert.Nil(t, setIntValue(reflect.ValueOf(&i).Elem(), 5))
+	assert.Equal(t, int32(5), i)
+	assert.Nil(t, setIntValue(reflect.ValueOf(&u).Elem(), 6))
+	assert.Equal(t, uint(6), u)
+	assert.Equal(t, errorWrongAutoIncrKey, setIntValue(reflect.ValueOf(&s).Elem(), 7))
+}
@@ -0,0 +1,254 @@
+package sqlagent
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx/reflectx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+var (
+	errorWrongCursor  = errors.New("pagination cursor error")
+	errorWrongKeys    = errors.New("pagination keys error")
+	errorWrongKeyType = errors.New("pagination key type unsupported error")
+)
+
+// keysetKey is key column of keyset pagination.
+type keysetKey struct {
+	column string
+	desc   bool
+}
+
+// parseKeysetKeys parse key columns such as "id" or "created_at DESC".
+func parseKeysetKeys(keys []string) ([]keysetKey, error) {
+	if len(keys) == 0 {
+		return nil, errorWrongKeys
+	}
+	parsed := make([]keysetKey, len(keys))
+	for i, key := range keys {
+		fields := strings.Fields(key)
+		switch {
+		case len(fields) == 1:
+		case len(fields) == 2 && strings.EqualFold(fields[1], "ASC"):
+		case len(fields) == 2 && strings.EqualFold(fields[1], "DESC"):
+			parsed[i].desc = true
+		default:
+			return nil, errorWrongKeys
+		}
+		parsed[i].column = fields[0]
+	}
+	return parsed, nil
+}
+
+// KeysetPage select one page of at most size rows after cursor to dest, ordered by key columns.
+// keys are unique ordered columns such as "id" or "created_at DESC", "id DESC".
+// cursor is returned by previous page, empty cursor means the first page.
+// It returns cursor of next page, which is empty if there is no more row.
+// builder should not have ORDER BY and LIMIT, dest should be pointer to slice of struct.
+// Key fields should be number, string, bool, time.Time or driver.Valuer of them, and not NULL.
+func (a *SqlAgent) KeysetPage(ctx context.Context, builder sq.SelectBuilder, keys []string, cursor string,
+	size uint64, dest interface{}) (string, error) {
+	return keysetPage(ctx, a, a.dbType, builder, keys, cursor, size, dest)
+}
+
+func keysetPage(ctx context.Context, ex Executor, dbType string, builder sq.SelectBuilder, keys []string, cursor string,
+	size uint64, dest interface{}) (string, error) {
+	parsed, err := parseKeysetKeys(keys)
+	if err != nil {
+		return "", err
+	}
+	if cursor != "" {
+		values, err := decodeCursor(cursor)
+		if err != nil {
+			return "", err
+		}
+		if len(values) != len(parsed) {
+			return "", errorWrongCursor
+		}
+		builder = builder.Where(keysetCondition(dbType, parsed, values))
+	}
+	builder = builder.OrderBy(keys...).Limit(size)
+	if err := ex.SelectContext(ctx, builder, dest); err != nil {
+		return "", err
+	}
+
+	rows := reflect.Indirect(reflect.ValueOf(dest))
+	if size == 0 || uint64(rows.Len()) < size {
+		return "", nil
+	}
+	return nextCursor(ex.Mapper(), rows.Index(rows.Len()-1), parsed)
+}
+
+// keysetCondition return condition of rows after values.
+// Row value comparison `(a, b) > (?, ?)` is used if all keys have the same order and dbType supports it,
+// otherwise it is expanded to `a > ? OR (a = ? AND b > ?)`.
+func keysetCondition(dbType string, keys []keysetKey, values []interface{}) sq.Sqlizer {
+	sameOrder := true
+	for _, k := range keys[1:] {
+		if k.desc != keys[0].desc {
+			sameOrder = false
+		}
+	}
+	if sameOrder && (dbType == dsncfg.MySql || dbType == dsncfg.Postgresql) {
+		columns := make([]string, len(keys))
+		for i, k := range keys {
+			columns[i] = k.column
+		}
+		op := ">"
+		if keys[0].desc {
+			op = "<"
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+		return sq.Expr("("+strings.Join(columns, ", ")+") "+op+" ("+placeholders+")", values...)
+	}
+
+	or := sq.Or{}
+	for i, k := range keys {
+		and := sq.And{}
+		for j := 0; j < i; j++ {
+			and = append(and, sq.Eq{keys[j].column: values[j]})
+		}
+		if k.desc {
+			and = append(and, sq.Expr(k.column+" < ?", values[i]))
+		} else {
+			and = append(and, sq.Expr(k.column+" > ?", values[i]))
+		}
+		or = append(or, and)
+	}
+	return or
+}
+
+// nextCursor encode values of key columns of row.
+func nextCursor(mapper *reflectx.Mapper, row reflect.Value, keys []keysetKey) (string, error) {
+	row = reflect.Indirect(row)
+	if row.Kind() != reflect.Struct {
+		return "", errorWrongModel
+	}
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		column := k.column
+		if idx := strings.LastIndex(column, "."); idx >= 0 {
+			column = column[idx+1:]
+		}
+		fi := mapper.TypeMap(row.Type()).GetByPath(column)
+		if fi == nil {
+			return "", errorWrongKeys
+		}
+		v, err := cursorKeyValue(reflectx.FieldByIndexesReadOnly(row, fi.Index).Interface())
+		if err != nil {
+			return "", err
+		}
+		values[i] = v
+	}
+	return encodeCursor(values)
+}
+
+// cursorKeyValue return value of key field which can be encoded in cursor and decoded as the same type.
+// driver.Valuer such as sql.NullInt64 is unwrapped, NULL, []byte and other types are unsupported.
+func cursorKeyValue(v interface{}) (interface{}, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := v.(time.Time); ok {
+		return v, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return rv.Interface(), nil
+	case reflect.Struct:
+		if t, ok := rv.Interface().(time.Time); ok {
+			return t, nil
+		}
+	}
+	return nil, errorWrongKeyType
+}
+
+// cursorTypeTime tag time.Time value of cursor, which is decoded from RFC3339 string.
+const cursorTypeTime = "time"
+
+// cursorValue is value of cursor tagged with its type, T is empty for value decoded as json.
+type cursorValue struct {
+	T string      `json:"t,omitempty"`
+	V interface{} `json:"v"`
+}
+
+func encodeCursor(values []interface{}) (string, error) {
+	tagged := make([]cursorValue, len(values))
+	for i, v := range values {
+		tagged[i].V = v
+		if _, ok := v.(time.Time); ok {
+			tagged[i].T = cursorTypeTime
+		}
+	}
+	data, err := json.Marshal(tagged)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errorWrongCursor
+	}
+	var tagged []cursorValue
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep integer precision
+	decoder.UseNumber()
+	if err := decoder.Decode(&tagged); err != nil {
+		return nil, errorWrongCursor
+	}
+	values := make([]interface{}, len(tagged))
+	for i, tv := range tagged {
+		switch tv.T {
+		case "":
+			if n, ok := tv.V.(json.Number); ok {
+				values[i] = cursorNumber(n)
+			} else {
+				values[i] = tv.V
+			}
+		case cursorTypeTime:
+			str, ok := tv.V.(string)
+			if !ok {
+				return nil, errorWrongCursor
+			}
+			t, err := time.Parse(time.RFC3339Nano, str)
+			if err != nil {
+				return nil, errorWrongCursor
+			}
+			values[i] = t
+		default:
+			return nil, errorWrongCursor
+		}
+	}
+	return values, nil
+}
+
+func cursorNumber(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
@@ -0,0 +1,124 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+type keysetRow struct {
+	ID        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func TestKeysetPage(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ex := newFakeExecutor(dsncfg.MySql)
+	ex.selectResult = []keysetRow{{ID: 1, CreatedAt: created}, {ID: 1 << 60, CreatedAt: created}}
+	builder := ex.SelectBuilder("id", "created_at").From("log").Where(sq.Eq{"level": 1})
+	keys := []string{"created_at DESC", "id DESC"}
+
+	var rows []keysetRow
+	cursor, err := keysetPage(context.TODO(), ex, dsncfg.MySql, builder, keys, "", 2, &rows)
+	assert.Nil(t, err)
+	assert.Equal(t, ex.selectResult, rows)
+	assert.NotEqual(t, "", cursor)
+	assert.Equal(t, "SELECT id, created_at FROM log WHERE level = ? ORDER BY created_at DESC, id DESC LIMIT 2",
+		ex.queries[0].SQL)
+
+	cursor, err = keysetPage(context.TODO(), ex, dsncfg.MySql, builder, keys, cursor, 3, &rows)
+	assert.Nil(t, err)
+	assert.Equal(t, "", cursor)
+	assert.Equal(t, "SELECT id, created_at FROM log WHERE level = ? AND (created_at, id) < (?, ?) "+
+		"ORDER BY created_at DESC, id DESC LIMIT 3", ex.queries[1].SQL)
+	args := ex.queries[1].Args
+	assert.Equal(t, 3, len(args))
+	assert.True(t, created.Equal(args[1].(time.Time)))
+	assert.Equal(t, int64(1<<60), args[2])
+
+	_, err = keysetPage(context.TODO(), ex, dsncfg.MySql, builder, keys, "bad cursor", 2, &rows)
+	assert.Equal(t, errorWrongCursor, err)
+	_, err = keysetPage(context.TODO(), ex, dsncfg.MySql, builder, nil, "", 2, &rows)
+	assert.Equal(t, errorWrongKeys, err)
+}
+
+func TestCursor(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	// string key which looks like timestamp is kept as string
+	values := []interface{}{created, "2020-01-02T03:04:05Z", int64(1 << 60), "a"}
+	cursor, err := encodeCursor(values)
+	assert.Nil(t, err)
+	decoded, err := decodeCursor(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(decoded))
+	assert.True(t, created.Equal(decoded[0].(time.Time)))
+	assert.Equal(t, values[1:], decoded[1:])
+
+	for _, data := range []string{`[{"t":"time","v":1}]`, `[{"t":"time","v":"a"}]`, `[{"t":"uuid","v":"a"}]`} {
+		_, err = decodeCursor(base64.RawURLEncoding.EncodeToString([]byte(data)))
+		assert.Equal(t, errorWrongCursor, err)
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	keys, err := parseKeysetKeys([]string{"a", "b"})
+	assert.Nil(t, err)
+	sqlStr, args, err := keysetCondition(dsncfg.Postgresql, keys, []interface{}{1, 2}).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "(a, b) > (?, ?)", sqlStr)
+	assert.Equal(t, []interface{}{1, 2}, args)
+
+	sqlStr, args, err = keysetCondition(dsncfg.Sqlite, keys, []interface{}{1, 2}).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "((a > ?) OR (a = ? AND b > ?))", sqlStr)
+	assert.Equal(t, []interface{}{1, 1, 2}, args)
+
+	keys, err = parseKeysetKeys([]string{"a DESC", "b ASC"})
+	assert.Nil(t, err)
+	sqlStr, _, err = keysetCondition(dsncfg.MySql, keys, []interface{}{1, 2}).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "((a < ?) OR (a = ? AND b > ?))", sqlStr)
+
+	_, err = parseKeysetKeys([]string{"a DOWN"})
+	assert.Equal(t, errorWrongKeys, err)
+}
+
+func TestNextCursor_KeyTypes(t *testing.T) {
+	type row struct {
+		Seq       sql.NullInt64 `db:"seq"`
+		CreatedAt sql.NullTime  `db:"created_at"`
+		Level     *int          `db:"level"`
+		Data      []byte        `db:"data"`
+	}
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	level := 2
+	mapper := reflectx.NewMapperFunc("db", strings.ToLower)
+	r := row{Seq: sql.NullInt64{Int64: 7, Valid: true}, CreatedAt: sql.NullTime{Time: created, Valid: true},
+		Level: &level, Data: []byte("a")}
+
+	cursor, err := nextCursor(mapper, reflect.ValueOf(r), []keysetKey{{column: "seq"}, {column: "created_at"}, {column: "level"}})
+	assert.Nil(t, err)
+	values, err := decodeCursor(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), values[0])
+	assert.True(t, created.Equal(values[1].(time.Time)))
+	assert.Equal(t, int64(2), values[2])
+
+	_, err = nextCursor(mapper, reflect.ValueOf(r), []keysetKey{{column: "data"}})
+	assert.Equal(t, errorWrongKeyType, err)
+	r.Seq.Valid = false
+	_, err = nextCursor(mapper, reflect.ValueOf(r), []keysetKey{{column: "seq"}})
+	assert.Equal(t, errorWrongKeyType, err)
+	r.Level = nil
+	_, err = nextCursor(mapper, reflect.ValueOf(r), []keysetKey{{column: "level"}})
+	assert.Equal(t, errorWrongKeyType, err)
+}
@@ -0,0 +1,303 @@
+package sqlagent
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lann/builder"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// DefaultLatencyBuckets are upper bounds in seconds of query latency histogram.
+var DefaultLatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	operationInsert = "insert"
+	operationUpdate = "update"
+	operationDelete = "delete"
+	operationSelect = "select"
+	operationOther  = "other"
+)
+
+type queryLabels struct {
+	operation string
+	table     string
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// Metrics is a Hook which collect query, transaction and connection pool metrics of SqlAgent.
+// It implements http.Handler to export metrics in prometheus text exposition format.
+type Metrics struct {
+	name    string
+	agent   *SqlAgent
+	buckets []float64
+
+	mu        sync.Mutex
+	latencies map[queryLabels]*histogram
+	errors    map[queryLabels]uint64
+	commits   uint64
+	rollbacks uint64
+}
+
+// NewMetrics create Metrics for SqlAgent and add it as hook of SqlAgent.
+// name is used as label "agent" of all metrics.
+func NewMetrics(name string, agent *SqlAgent) *Metrics {
+	m := &Metrics{
+		name:      name,
+		agent:     agent,
+		buckets:   DefaultLatencyBuckets,
+		latencies: make(map[queryLabels]*histogram),
+		errors:    make(map[queryLabels]uint64),
+	}
+	agent.AddHook(m)
+	return m
+}
+
+func (m *Metrics) Before(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (m *Metrics) After(ctx context.Context, event *QueryEvent) {
+	labels := queryLabels{
+		operation: builderOperation(event.Builder),
+		table:     builderTable(event.Builder),
+	}
+	seconds := event.Duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.latencies[labels]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(m.buckets))}
+		m.latencies[labels] = h
+	}
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+	if event.Err != nil {
+		m.errors[labels]++
+	}
+}
+
+func (m *Metrics) AfterTx(ctx context.Context, event *TxEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if event.Committed {
+		m.commits++
+	} else {
+		m.rollbacks++
+	}
+}
+
+// ServeHTTP write metrics in prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	MetricsHandler(m).ServeHTTP(w, r)
+}
+
+// MetricsHandler return http.Handler which export metrics of multiple SqlAgent.
+func MetricsHandler(metrics ...*Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, metrics...)
+	})
+}
+
+type metricFamily struct {
+	name string
+	help string
+	typ  string
+	// write samples of one Metrics
+	write func(m *Metrics, buf *bytes.Buffer, name string)
+}
+
+var metricFamilies = []metricFamily{
+	{"sqlagent_query_duration_seconds", "Query latency in seconds.", "histogram", (*Metrics).writeLatencies},
+	{"sqlagent_query_errors_total", "Number of failed queries.", "counter", (*Metrics).writeErrors},
+	{"sqlagent_transactions_total", "Number of finished transactions.", "counter", (*Metrics).writeTransactions},
+	{"sqlagent_pool_max_open_connections", "Maximum number of open connections.", "gauge", poolStat(func(s sql.DBStats) float64 {
+		return float64(s.MaxOpenConnections)
+	})},
+	{"sqlagent_pool_open_connections", "Number of established connections.", "gauge", poolStat(func(s sql.DBStats) float64 {
+		return float64(s.OpenConnections)
+	})},
+	{"sqlagent_pool_in_use_connections", "Number of connections in use.", "gauge", poolStat(func(s sql.DBStats) float64 {
+		return float64(s.InUse)
+	})},
+	{"sqlagent_pool_idle_connections", "Number of idle connections.", "gauge", poolStat(func(s sql.DBStats) float64 {
+		return float64(s.Idle)
+	})},
+	{"sqlagent_pool_wait_count_total", "Number of connections waited for.", "counter", poolStat(func(s sql.DBStats) float64 {
+		return float64(s.WaitCount)
+	})},
+	{"sqlagent_pool_wait_duration_seconds_total", "Total time blocked waiting for connection.", "counter", poolStat(func(s sql.DBStats) float64 {
+		return s.WaitDuration.Seconds()
+	})},
+	{"sqlagent_pool_max_idle_closed_total", "Number of connections closed due to SetMaxIdleConns.", "counter", poolStat(func(s sql.DBStats) float64 {
+		return float64(s.MaxIdleClosed)
+	})},
+	{"sqlagent_pool_max_lifetime_closed_total", "Number of connections closed due to SetConnMaxLifetime.", "counter", poolStat(func(s sql.DBStats) float64 {
+		return float64(s.MaxLifetimeClosed)
+	})},
+}
+
+// WriteMetrics write metrics of multiple SqlAgent to w in prometheus text exposition format.
+func WriteMetrics(w io.Writer, metrics ...*Metrics) (int64, error) {
+	buf := &bytes.Buffer{}
+	for _, family := range metricFamilies {
+		fmt.Fprintf(buf, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family.name, family.typ)
+		for _, m := range metrics {
+			family.write(m, buf, family.name)
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+func (m *Metrics) sortedLabels() []queryLabels {
+	labels := make([]queryLabels, 0, len(m.latencies))
+	for l := range m.latencies {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].operation != labels[j].operation {
+			return labels[i].operation < labels[j].operation
+		}
+		return labels[i].table < labels[j].table
+	})
+	return labels
+}
+
+func (m *Metrics) writeLatencies(buf *bytes.Buffer, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, l := range m.sortedLabels() {
+		h := m.latencies[l]
+		labels := m.labels("operation", l.operation, "table", l.table)
+		for i, upper := range m.buckets {
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(upper), h.buckets[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func (m *Metrics) writeErrors(buf *bytes.Buffer, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, l := range m.sortedLabels() {
+		fmt.Fprintf(buf, "%s{%s} %d\n", name,
+			m.labels("operation", l.operation, "table", l.table), m.errors[l])
+	}
+}
+
+func (m *Metrics) writeTransactions(buf *bytes.Buffer, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(buf, "%s{%s} %d\n", name, m.labels("result", "commit"), m.commits)
+	fmt.Fprintf(buf, "%s{%s} %d\n", name, m.labels("result", "rollback"), m.rollbacks)
+}
+
+// poolStat return func to write one sql.DBStats field of primary and replicas.
+func poolStat(stat func(s sql.DBStats) float64) func(m *Metrics, buf *bytes.Buffer, name string) {
+	return func(m *Metrics, buf *bytes.Buffer, name string) {
+		fmt.Fprintf(buf, "%s{%s} %s\n", name, m.labels("db", "primary"),
+			formatFloat(stat(m.agent.db.Stats())))
+		for i, r := range m.agent.replicas {
+			fmt.Fprintf(buf, "%s{%s} %s\n", name, m.labels("db", "replica-"+strconv.Itoa(i)),
+				formatFloat(stat(r.db.Stats())))
+		}
+	}
+}
+
+// labels format label pairs with agent label.
+func (m *Metrics) labels(kv ...string) string {
+	pairs := make([]string, 0, len(kv)/2+1)
+	pairs = append(pairs, fmt.Sprintf("agent=\"%s\"", escapeLabelValue(m.name)))
+	for i := 0; i+1 < len(kv); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", kv[i], escapeLabelValue(kv[i+1])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// builderOperation return sql operation of squirrel builder.
+func builderOperation(b sq.Sqlizer) string {
+	switch b.(type) {
+	case sq.InsertBuilder:
+		return operationInsert
+	case sq.UpdateBuilder:
+		return operationUpdate
+	case sq.DeleteBuilder:
+		return operationDelete
+	case sq.SelectBuilder:
+		return operationSelect
+	}
+	return operationOther
+}
+
+// builderTable return table name of squirrel builder, return empty string if unknown.
+func builderTable(b sq.Sqlizer) string {
+	var field string
+	switch b.(type) {
+	case sq.InsertBuilder:
+		field = "Into"
+	case sq.UpdateBuilder:
+		field = "Table"
+	case sq.DeleteBuilder, sq.SelectBuilder:
+		field = "From"
+	default:
+		return ""
+	}
+	v, ok := builder.Get(b, field)
+	if !ok {
+		return ""
+	}
+	var table string
+	switch from := v.(type) {
+	case string:
+		table = from
+	case sq.Sqlizer:
+		table, _, _ = from.ToSql()
+	}
+	if strings.HasPrefix(table, "(") {
+		// sub query
+		return ""
+	}
+	if fields := strings.Fields(table); len(fields) > 0 {
+		// strip table alias
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package sqlagent
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+func TestBuilderOperationTable(t *testing.T) {
+	cases := []struct {
+		builder   sq.Sqlizer
+		operation string
+		table     string
+	}{
+		{sq.Insert("user").Values(1), operationInsert, "user"},
+		{sq.Update("user").Set("a", 1), operationUpdate, "user"},
+		{sq.Delete("user"), operationDelete, "user"},
+		{sq.Select("*").From("user u"), operationSelect, "user"},
+		{sq.Select("*").FromSelect(sq.Select("*").From("user"), "t"), operationSelect, ""},
+		{sq.Expr("SELECT 1"), operationOther, ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.operation, builderOperation(c.builder))
+		assert.Equal(t, c.table, builderTable(c.builder))
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	db, err := sqlx.Open("mysql", "user@tcp(127.0.0.1:1)/test")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	a := &SqlAgent{db: db}
+	m := NewMetrics("orders", a)
+	assert.Equal(t, 1, len(a.hooks))
+
+	m.After(context.TODO(), &QueryEvent{Builder: sq.Select("*").From("order"), Duration: 3 * time.Millisecond})
+	m.After(context.TODO(), &QueryEvent{Builder: sq.Select("*").From("order"), Duration: 2 * time.Second,
+		Err: errors.New("timeout")})
+	m.After(context.TODO(), &QueryEvent{Builder: sq.Insert("order").Values(1), Duration: time.Millisecond})
+	m.AfterTx(context.TODO(), &TxEvent{Committed: true})
+	m.AfterTx(context.TODO(), &TxEvent{Committed: false})
+	m.AfterTx(context.TODO(), &TxEvent{Committed: true})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	t.Log(body)
+
+	expects := []string{
+		"# TYPE sqlagent_query_duration_seconds histogram",
+		`sqlagent_query_duration_seconds_bucket{agent="orders",operation="select",table="order",le="0.005"} 1`,
+		`sqlagent_query_duration_seconds_bucket{agent="orders",operation="select",table="order",le="2.5"} 2`,
+		`sqlagent_query_duration_seconds_bucket{agent="orders",operation="select",table="order",le="+Inf"} 2`,
+		`sqlagent_query_duration_seconds_count{agent="orders",operation="insert",table="order"} 1`,
+		`sqlagent_query_errors_total{agent="orders",operation="select",table="order"} 1`,
+		`sqlagent_query_errors_total{agent="orders",operation="insert",table="order"} 0`,
+		`sqlagent_transactions_total{agent="orders",result="commit"} 2`,
+		`sqlagent_transactions_total{agent="orders",result="rollback"} 1`,
+		`sqlagent_pool_open_connections{agent="orders",db="primary"} 0`,
+	}
+	for _, expect := range expects {
+		assert.True(t, strings.Contains(body, expect+"\n"), expect)
+	}
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabelValue("a\"b\\c\nd"))
+}
@@ -0,0 +1,119 @@
+package sqlagent
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Tag options of model field, e.g. `db:"id,pk"`.
+const (
+	// tagOptionPK mark primary key column.
+	tagOptionPK = "pk"
+	// tagOptionAutoIncr mark auto increment column, it is skipped by insert if zero value.
+	tagOptionAutoIncr = "autoincr"
+	// tagOptionVersion mark version column of optimistic locking.
+	tagOptionVersion = "version"
+	// tagOptionSoftDelete mark soft delete column, it should be nullable such as *time.Time or sql.NullTime.
+	tagOptionSoftDelete = "softdelete"
+	// tagOptionCreated mark created time column, it is set by insert if zero value.
+	tagOptionCreated = "created"
+	// tagOptionUpdated mark updated time column, it is set by insert if zero value and always set by update.
+	tagOptionUpdated = "updated"
+)
+
+// defaultPrimaryKey is primary key column if no field is marked by tag option "pk".
+const defaultPrimaryKey = "id"
+
+var (
+	errorWrongModel       = errors.New("model should be struct error")
+	errorNoPrimaryKey     = errors.New("model has no primary key error")
+	errorWrongAutoIncrKey = errors.New("auto increment field should be integer error")
+	errorWrongVersionKey  = errors.New("version field should be integer error")
+)
+
+// TableNamer is implemented by model which defines its table name.
+type TableNamer interface {
+	TableName() string
+}
+
+// modelTableName return table name of model type.
+// Use TableName of model if it implements TableNamer, otherwise use snake case of type name.
+func modelTableName(t reflect.Type) string {
+	t = reflectx.Deref(t)
+	if namer, ok := reflect.New(t).Interface().(TableNamer); ok {
+		return namer.TableName()
+	}
+	if namer, ok := reflect.New(t).Elem().Interface().(TableNamer); ok {
+		return namer.TableName()
+	}
+	return toSnakeCase(t.Name())
+}
+
+// primaryKeyField return field marked by tag option "pk", or field with column name "id".
+func primaryKeyField(sm *reflectx.StructMap) *reflectx.FieldInfo {
+	if fi := fieldWithOption(sm, tagOptionPK); fi != nil {
+		return fi
+	}
+	return sm.Names[defaultPrimaryKey]
+}
+
+// fieldWithOption return the first column field with tag option.
+func fieldWithOption(sm *reflectx.StructMap, option string) *reflectx.FieldInfo {
+	for _, fi := range sm.Index {
+		if _, ok := fi.Options[option]; ok && !fi.Embedded {
+			return fi
+		}
+	}
+	return nil
+}
+
+func hasTagOption(fi *reflectx.FieldInfo, option string) bool {
+	_, ok := fi.Options[option]
+	return ok
+}
+
+func isVersionField(fi *reflectx.FieldInfo) bool {
+	return hasTagOption(fi, tagOptionVersion)
+}
+
+func isAutoIncrField(fi *reflectx.FieldInfo) bool {
+	return hasTagOption(fi, tagOptionAutoIncr)
+}
+
+func isTimestampField(fi *reflectx.FieldInfo) bool {
+	return hasTagOption(fi, tagOptionCreated) || hasTagOption(fi, tagOptionUpdated)
+}
+
+// setIntValue set generated id to integer field.
+func setIntValue(field reflect.Value, id int64) error {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(id))
+	default:
+		return errorWrongAutoIncrKey
+	}
+	return nil
+}
+
+// toSnakeCase convert CamelCase name to snake_case, e.g. UserOrder -> user_order, HTTPLog -> http_log.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/jmoiron/sqlx/reflectx"
 	sq "gopkg.in/Masterminds/squirrel.v1"
+	"time"
 )
 
 var (
+	// defaultAgent is the SqlAgent registered with DefaultAgentName.
 	defaultAgent *SqlAgent
 )
 
@@ -27,6 +29,21 @@ func Transaction(ctx context.Context, opt *sql.TxOptions, fn func(tx *sqlx.Tx) e
 	return defaultAgent.Transaction(ctx, opt, fn)
 }
 
+// TransactionContext run fn in transaction of module sqlagent, nested call runs in savepoint.
+func TransactionContext(ctx context.Context, opt *sql.TxOptions, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	return defaultAgent.TransactionContext(ctx, opt, fn)
+}
+
+// WrapTx return Tx of module sqlagent which implements Executor.
+func WrapTx(tx *sqlx.Tx) *Tx {
+	return defaultAgent.WrapTx(tx)
+}
+
+// TransactionWithRetry run fn in transaction of module sqlagent and retry it on deadlock or serialization failure.
+func TransactionWithRetry(ctx context.Context, opt *sql.TxOptions, policy *RetryPolicy, fn func(tx *sqlx.Tx) error) (int, error) {
+	return defaultAgent.TransactionWithRetry(ctx, opt, policy, fn)
+}
+
 // InsertBuilder return squirrel.InsertBuilder for table into
 // into: insert table name
 func InsertBuilder(into string) sq.InsertBuilder {
@@ -49,10 +66,40 @@ func InsertModelBuilder(into string, model interface{}, ignoreColumns ...string)
 	return defaultAgent.InsertModelBuilder(into, model, ignoreColumns...)
 }
 
+func UpsertModelBuilder(into string, model interface{}, conflictColumns, insertOnlyColumns []string,
+	ignoreColumns ...string) (sq.InsertBuilder, error) {
+	return defaultAgent.UpsertModelBuilder(into, model, conflictColumns, insertOnlyColumns, ignoreColumns...)
+}
+
+// InsertModel insert model by module sqlagent and set generated id to field tagged with "autoincr".
+func InsertModel(ctx context.Context, into string, model interface{}, ignoreColumns ...string) (sql.Result, error) {
+	return defaultAgent.InsertModel(ctx, into, model, ignoreColumns...)
+}
+
+// InsertModels insert models by module sqlagent and return total rows affected.
+func InsertModels(ctx context.Context, into string, models interface{}, ignoreColumns ...string) (int64, error) {
+	return defaultAgent.InsertModels(ctx, into, models, ignoreColumns...)
+}
+
+// InsertModelsTx insert models by module sqlagent in one transaction and return total rows affected.
+func InsertModelsTx(ctx context.Context, into string, models interface{}, ignoreColumns ...string) (int64, error) {
+	return defaultAgent.InsertModelsTx(ctx, into, models, ignoreColumns...)
+}
+
+// UpdateModel update model by module sqlagent, see SqlAgent.UpdateModel.
+func UpdateModel(ctx context.Context, updateBuilder sq.UpdateBuilder, model interface{},
+	opts ...UpdateOption) (sql.Result, error) {
+	return defaultAgent.UpdateModel(ctx, updateBuilder, model, opts...)
+}
+
 func SetUpdateColumns(updateBuilder sq.UpdateBuilder, model interface{}, ignoreColumns ...string) sq.UpdateBuilder {
 	return defaultAgent.SetUpdateColumns(updateBuilder, model, ignoreColumns...)
 }
 
+func SetUpdateColumnsWith(updateBuilder sq.UpdateBuilder, model interface{}, opts ...UpdateOption) sq.UpdateBuilder {
+	return defaultAgent.SetUpdateColumnsWith(updateBuilder, model, opts...)
+}
+
 // SetDBMapper set mapper for module sqlagent
 func SetDBMapper(mapper *reflectx.Mapper) {
 	defaultAgent.SetDBMapper(mapper)
@@ -63,6 +110,51 @@ func SetConnectionConfig(cfg dsncfg.ConnectionConfig) {
 	defaultAgent.SetConnectionConfig(cfg)
 }
 
+// AddHook add hooks to module sqlagent.
+func AddHook(hooks ...Hook) {
+	defaultAgent.AddHook(hooks...)
+}
+
+// SetClock set clock of created and updated time columns of module sqlagent.
+func SetClock(now func() time.Time) {
+	defaultAgent.SetClock(now)
+}
+
+// SetTimeLocation set time zone of created and updated time columns of module sqlagent.
+func SetTimeLocation(loc *time.Location) {
+	defaultAgent.SetTimeLocation(loc)
+}
+
+// EnableStmtCache cache at most size prepared statements of module sqlagent.
+func EnableStmtCache(size int) {
+	defaultAgent.EnableStmtCache(size)
+}
+
+// StmtCacheStats return statistics of prepared statement cache of module sqlagent.
+func StmtCacheStats() CacheStats {
+	return defaultAgent.StmtCacheStats()
+}
+
+// SetPanicHandler set handler to report panic recovered by module sqlagent.
+func SetPanicHandler(h PanicHandler) {
+	defaultAgent.SetPanicHandler(h)
+}
+
+// SetTxPanicRecovery set whether to recover panic in transaction func of module sqlagent.
+func SetTxPanicRecovery(enable bool) {
+	defaultAgent.SetTxPanicRecovery(enable)
+}
+
+// SetPlaceholderFormat set placeholder format of builders created by module sqlagent.
+func SetPlaceholderFormat(f sq.PlaceholderFormat) {
+	defaultAgent.SetPlaceholderFormat(f)
+}
+
+// SetBalancer set Balancer used by module sqlagent to pick replica.
+func SetBalancer(b Balancer) {
+	defaultAgent.SetBalancer(b)
+}
+
 // ModelColumns use module sqlagent to extract model columns.
 func ModelColumns(model interface{}, ignoreColumns ...string) []string {
 	return defaultAgent.ModelColumns(model, ignoreColumns...)
@@ -75,6 +167,7 @@ func ExecContext(ctx context.Context, builder sq.Sqlizer) (sql.Result, error) {
 }
 
 // GetContext get one record by sql built by sq.SelectBuilder and scan to dest.
+// Use errors.Is(err, ErrNotFound) to check no record is found, see SqlAgent.GetContext.
 // builder: sq.SelectBuilder
 func GetContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
 	return defaultAgent.GetContext(ctx, builder, dest)
@@ -85,3 +178,40 @@ func GetContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error
 func SelectContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
 	return defaultAgent.SelectContext(ctx, builder, dest)
 }
+
+// QueryContext query records by module sqlagent and return iterator of rows.
+// builder: sq.SelectBuilder
+func QueryContext(ctx context.Context, builder sq.Sqlizer) (*Rows, error) {
+	return defaultAgent.QueryContext(ctx, builder)
+}
+
+// KeysetPage select one page of rows after cursor by module sqlagent, see SqlAgent.KeysetPage.
+func KeysetPage(ctx context.Context, builder sq.SelectBuilder, keys []string, cursor string, size uint64,
+	dest interface{}) (string, error) {
+	return defaultAgent.KeysetPage(ctx, builder, keys, cursor, size, dest)
+}
+
+// Paginate select rows of page and count rows of all pages by module sqlagent, see SqlAgent.Paginate.
+func Paginate(ctx context.Context, selectBuilder sq.SelectBuilder, page, size uint64, dest interface{}) (Page, error) {
+	return defaultAgent.Paginate(ctx, selectBuilder, page, size, dest)
+}
+
+// Count return number of rows selected by builder by module sqlagent.
+func Count(ctx context.Context, selectBuilder sq.SelectBuilder) (uint64, error) {
+	return defaultAgent.Count(ctx, selectBuilder)
+}
+
+// Exists return whether builder selects any row by module sqlagent.
+func Exists(ctx context.Context, selectBuilder sq.SelectBuilder) (bool, error) {
+	return defaultAgent.Exists(ctx, selectBuilder)
+}
+
+// Pluck select one column of rows selected by builder to dest by module sqlagent.
+func Pluck(ctx context.Context, selectBuilder sq.SelectBuilder, column string, dest interface{}) error {
+	return defaultAgent.Pluck(ctx, selectBuilder, column, dest)
+}
+
+// SelectMaps select rows as maps of column name to value by module sqlagent.
+func SelectMaps(ctx context.Context, builder sq.Sqlizer) ([]map[string]interface{}, error) {
+	return defaultAgent.SelectMaps(ctx, builder)
+}
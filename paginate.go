@@ -0,0 +1,94 @@
+package sqlagent
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lann/builder"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// countAlias is alias of subquery wrapped by count query.
+const countAlias = "sqlagent_count"
+
+// Page is metadata of page selected by Paginate.
+type Page struct {
+	// Page is page number starting from 1.
+	Page uint64 `json:"page"`
+	// Size is max number of rows in page.
+	Size uint64 `json:"size"`
+	// Total is number of rows of all pages.
+	Total uint64 `json:"total"`
+	// TotalPages is number of pages.
+	TotalPages uint64 `json:"total_pages"`
+}
+
+// HasNext return whether there is page after it.
+func (p Page) HasNext() bool {
+	return p.Page < p.TotalPages
+}
+
+// Paginate select rows of page to dest and count rows of all pages.
+// page starts from 1, page 0 is the same as 1. dest should be pointer to slice.
+// Count query is rewritten from builder, see CountBuilder.
+func (a *SqlAgent) Paginate(ctx context.Context, selectBuilder sq.SelectBuilder, page, size uint64,
+	dest interface{}) (Page, error) {
+	return paginate(ctx, a, selectBuilder, page, size, dest)
+}
+
+func paginate(ctx context.Context, ex Executor, selectBuilder sq.SelectBuilder, page, size uint64,
+	dest interface{}) (Page, error) {
+	if size == 0 {
+		return Page{}, errorWrongArgs
+	}
+	if page == 0 {
+		page = 1
+	}
+	p := Page{Page: page, Size: size}
+	if err := ex.GetContext(ctx, CountBuilder(selectBuilder), &p.Total); err != nil {
+		return p, err
+	}
+	p.TotalPages = (p.Total + size - 1) / size
+
+	selectBuilder = selectBuilder.Limit(size).Offset((page - 1) * size)
+	if err := ex.SelectContext(ctx, selectBuilder, dest); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// CountBuilder rewrite select into query which counts its rows.
+// ORDER BY, LIMIT and OFFSET are removed, select with GROUP BY or DISTINCT is wrapped in subquery,
+// otherwise columns are replaced by COUNT(*).
+func CountBuilder(selectBuilder sq.SelectBuilder) sq.SelectBuilder {
+	b := builder.Delete(selectBuilder, "OrderBys")
+	b = builder.Delete(b, "Limit")
+	b = builder.Delete(b, "Offset")
+	selectBuilder = b.(sq.SelectBuilder)
+
+	if !isGroupedSelect(selectBuilder) {
+		return builder.Delete(selectBuilder, "Columns").(sq.SelectBuilder).Columns("COUNT(*)")
+	}
+	countBuilder := sq.Select("COUNT(*)")
+	if f, ok := builder.Get(selectBuilder, "PlaceholderFormat"); ok {
+		countBuilder = countBuilder.PlaceholderFormat(f.(sq.PlaceholderFormat))
+		// placeholders are replaced by outer query
+		selectBuilder = selectBuilder.PlaceholderFormat(sq.Question)
+	}
+	return countBuilder.FromSelect(selectBuilder, countAlias)
+}
+
+// isGroupedSelect return whether select has GROUP BY or DISTINCT.
+func isGroupedSelect(selectBuilder sq.SelectBuilder) bool {
+	if groupBys, ok := builder.Get(selectBuilder, "GroupBys"); ok && len(groupBys.([]string)) > 0 {
+		return true
+	}
+	if options, ok := builder.Get(selectBuilder, "Options"); ok {
+		for _, option := range options.([]string) {
+			if strings.EqualFold(option, "DISTINCT") {
+				return true
+			}
+		}
+	}
+	return false
+}
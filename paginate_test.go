@@ -0,0 +1,53 @@
+package sqlagent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+func TestCountBuilder(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "postgres"), dsncfg.Postgresql)
+	cases := []struct {
+		builder sq.SelectBuilder
+		expect  string
+	}{
+		{a.SelectBuilder("id", "name").From("user").Where(sq.Eq{"uid": 1}).OrderBy("id").Limit(10).Offset(20),
+			"SELECT COUNT(*) FROM user WHERE uid = $1"},
+		{a.SelectBuilder("uid", "COUNT(*)").From("user").Where(sq.Eq{"uid": 1}).GroupBy("uid").OrderBy("uid"),
+			"SELECT COUNT(*) FROM (SELECT uid, COUNT(*) FROM user WHERE uid = $1 GROUP BY uid) AS sqlagent_count"},
+		{a.SelectBuilder("name").Distinct().From("user").Where(sq.Eq{"uid": 1}).Limit(10),
+			"SELECT COUNT(*) FROM (SELECT DISTINCT name FROM user WHERE uid = $1) AS sqlagent_count"},
+	}
+	for _, c := range cases {
+		sqlStr, args, err := CountBuilder(c.builder).ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, c.expect, sqlStr)
+		assert.Equal(t, []interface{}{1}, args)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	ex := newFakeExecutor(dsncfg.MySql)
+	ex.selectResult = []repoUser{{ID: 3}}
+	ex.getResult = uint64(21)
+	var users []repoUser
+	page, err := paginate(context.TODO(), ex, ex.SelectBuilder("id", "name").From("user").OrderBy("id"), 3, 10, &users)
+	assert.Nil(t, err)
+	assert.Equal(t, Page{Page: 3, Size: 10, Total: 21, TotalPages: 3}, page)
+	assert.False(t, page.HasNext())
+	assert.Equal(t, ex.selectResult, users)
+	assert.Equal(t, "SELECT COUNT(*) FROM user", ex.queries[0].SQL)
+	assert.Equal(t, "SELECT id, name FROM user ORDER BY id LIMIT 10 OFFSET 20", ex.queries[1].SQL)
+
+	page, err = paginate(context.TODO(), ex, ex.SelectBuilder("id").From("user"), 0, 10, &users)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), page.Page)
+	assert.True(t, page.HasNext())
+	_, err = paginate(context.TODO(), ex, ex.SelectBuilder("id").From("user"), 1, 0, &users)
+	assert.Equal(t, errorWrongArgs, err)
+}
@@ -0,0 +1,84 @@
+package sqlagent
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PanicError is returned by transaction if panic in transaction func is recovered.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("transaction panic: %v", e.Value)
+}
+
+// Unwrap return panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// PanicHandler report recovered panic value and stack.
+type PanicHandler func(value interface{}, stack []byte)
+
+func defaultPanicHandler(value interface{}, stack []byte) {
+	defaultLogger.Printf("recovered panic: %v\n%s", value, stack)
+}
+
+// SetPanicHandler set handler to report panic recovered by SqlAgent, default log to stderr.
+func (a *SqlAgent) SetPanicHandler(h PanicHandler) {
+	a.panicHandler = h
+}
+
+func (a *SqlAgent) reportPanic(value interface{}, stack []byte) {
+	h := a.panicHandler
+	if h == nil {
+		h = defaultPanicHandler
+	}
+	h(value, stack)
+}
+
+// runCallbacks call callbacks in order, panic in callback is recovered and reported.
+func (a *SqlAgent) runCallbacks(callbacks []func()) {
+	for _, fn := range callbacks {
+		a.runCallback(fn)
+	}
+}
+
+func (a *SqlAgent) runCallback(fn func()) {
+	defer func() {
+		if p := recover(); p != nil {
+			a.reportPanic(p, debug.Stack())
+		}
+	}()
+	fn()
+}
+
+// SetTxPanicRecovery set whether to recover panic in transaction func.
+// If enabled, transaction is rolled back, panic is reported by PanicHandler and *PanicError is returned.
+// Otherwise transaction is rolled back and panic is raised again, this is the default.
+func (a *SqlAgent) SetTxPanicRecovery(enable bool) {
+	a.recoverTxPanic = enable
+}
+
+// callTxFunc call transaction func and recover panic if enabled.
+func (a *SqlAgent) callTxFunc(ctx context.Context, tx *sqlx.Tx, fn func(ctx context.Context, tx *sqlx.Tx) error) (err error) {
+	if a.recoverTxPanic {
+		defer func() {
+			if p := recover(); p != nil {
+				panicErr := &PanicError{Value: p, Stack: debug.Stack()}
+				a.reportPanic(p, panicErr.Stack)
+				err = panicErr
+			}
+		}()
+	}
+	return fn(ctx, tx)
+}
@@ -0,0 +1,77 @@
+package sqlagent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestPanic = errors.New("test panic")
+
+func TestSqlAgent_RunCallbacks(t *testing.T) {
+	a := &SqlAgent{}
+	var reported []interface{}
+	a.SetPanicHandler(func(value interface{}, stack []byte) {
+		reported = append(reported, value)
+		assert.NotEmpty(t, stack)
+	})
+
+	var calls []int
+	a.runCallbacks([]func(){
+		func() { calls = append(calls, 1) },
+		func() { panic("callback panic") },
+		func() { calls = append(calls, 3) },
+	})
+	assert.Equal(t, []int{1, 3}, calls)
+	assert.Equal(t, []interface{}{"callback panic"}, reported)
+}
+
+func TestAfterCommit(t *testing.T) {
+	tx := &sqlx.Tx{}
+	assert.Equal(t, errorTxNotFound, AfterCommit(tx, func() {}))
+	assert.Equal(t, errorTxNotFound, AfterRollback(tx, func() {}))
+
+	state := &txState{agent: &SqlAgent{}, tx: tx}
+	bindTxState(state)
+	defer unbindTxState(state)
+	assert.Nil(t, AfterCommit(tx, func() {}))
+	assert.Nil(t, state.agent.WrapTx(tx).AfterCommit(func() {}))
+	assert.Nil(t, AfterRollback(tx, func() {}))
+	assert.Equal(t, 2, len(state.afterCommit))
+	assert.Equal(t, 1, len(state.afterRollback))
+}
+
+func TestSqlAgent_CallTxFunc(t *testing.T) {
+	a := &SqlAgent{}
+	var reported []interface{}
+	a.SetPanicHandler(func(value interface{}, stack []byte) {
+		reported = append(reported, value)
+	})
+	panicFn := func(ctx context.Context, tx *sqlx.Tx) error {
+		panic(errTestPanic)
+	}
+
+	assert.Panics(t, func() {
+		a.callTxFunc(context.TODO(), nil, panicFn)
+	})
+	assert.Empty(t, reported)
+
+	a.SetTxPanicRecovery(true)
+	err := a.callTxFunc(context.TODO(), nil, panicFn)
+	panicErr, ok := err.(*PanicError)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.Equal(t, errTestPanic, panicErr.Value)
+	assert.True(t, errors.Is(err, errTestPanic))
+	assert.True(t, strings.Contains(string(panicErr.Stack), "panic_test.go"))
+	assert.Equal(t, []interface{}{errTestPanic}, reported)
+
+	assert.Nil(t, a.callTxFunc(context.TODO(), nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		return nil
+	}))
+}
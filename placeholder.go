@@ -0,0 +1,69 @@
+package sqlagent
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/lann/builder"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// QuestionNumber is a squirrel.PlaceholderFormat which replaces placeholders with
+// numbered question marks (e.g. ?1, ?2, ?3), it is supported by SQLite.
+var QuestionNumber sq.PlaceholderFormat = questionNumberFormat{}
+
+type questionNumberFormat struct{}
+
+func (questionNumberFormat) ReplacePlaceholders(sql string) (string, error) {
+	return replacePositionalPlaceholders(sql, "?"), nil
+}
+
+// replacePositionalPlaceholders replace "?" with prefix and position, "??" is escaped to "?".
+func replacePositionalPlaceholders(sql, prefix string) string {
+	buf := &bytes.Buffer{}
+	i := 0
+	for {
+		p := strings.Index(sql, "?")
+		if p == -1 {
+			break
+		}
+		buf.WriteString(sql[:p])
+		if len(sql[p:]) > 1 && sql[p+1] == '?' {
+			buf.WriteString("?")
+			sql = sql[p+2:]
+			continue
+		}
+		i++
+		buf.WriteString(prefix)
+		buf.WriteString(strconv.Itoa(i))
+		sql = sql[p+1:]
+	}
+	buf.WriteString(sql)
+	return buf.String()
+}
+
+// placeholderFormat return default placeholder format of database type.
+func placeholderFormat(dbType string) sq.PlaceholderFormat {
+	switch dbType {
+	case dsncfg.Postgresql:
+		return sq.Dollar
+	}
+	return sq.Question
+}
+
+// SetPlaceholderFormat set placeholder format of builders created by SqlAgent.
+// Default is sq.Dollar for postgres and sq.Question for mysql and sqlite,
+// sqlite also supports sq.Dollar and QuestionNumber.
+func (a *SqlAgent) SetPlaceholderFormat(f sq.PlaceholderFormat) {
+	a.builder = a.builder.PlaceholderFormat(f)
+}
+
+// PlaceholderFormat return placeholder format of builders created by SqlAgent.
+func (a *SqlAgent) PlaceholderFormat() sq.PlaceholderFormat {
+	if f, ok := builder.Get(a.builder, "PlaceholderFormat"); ok {
+		return f.(sq.PlaceholderFormat)
+	}
+	return sq.Question
+}
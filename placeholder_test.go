@@ -0,0 +1,57 @@
+package sqlagent
+
+import (
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+func TestQuestionNumber(t *testing.T) {
+	sqlStr, err := QuestionNumber.ReplacePlaceholders("a = ? AND b ?? c AND d IN (?,?)")
+	assert.Nil(t, err)
+	assert.Equal(t, "a = ?1 AND b ? c AND d IN (?2,?3)", sqlStr)
+}
+
+func TestSqlAgent_PlaceholderFormat(t *testing.T) {
+	mysqlAgent := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	pgAgent := newSqlAgent(sqlx.NewDb(nil, "postgres"), dsncfg.Postgresql)
+	sqliteAgent := newSqlAgent(sqlx.NewDb(nil, "sqlite3"), dsncfg.Sqlite)
+	assert.Equal(t, sq.Question, mysqlAgent.PlaceholderFormat())
+	assert.Equal(t, sq.Dollar, pgAgent.PlaceholderFormat())
+	assert.Equal(t, sq.Question, sqliteAgent.PlaceholderFormat())
+
+	type model struct {
+		Name string
+		UID  int64
+	}
+	cases := []struct {
+		builder sq.Sqlizer
+		sql     string
+	}{
+		{mysqlAgent.SelectBuilder("*").From("user").Where("id = ?", 1), "SELECT * FROM user WHERE id = ?"},
+		{pgAgent.SelectBuilder("*").From("user").Where("id = ?", 1), "SELECT * FROM user WHERE id = $1"},
+		{pgAgent.InsertModelBuilder("user", model{}), "INSERT INTO user (name,uid) VALUES ($1,$2)"},
+		{pgAgent.UpdateBuilder("user").Set("name", "a").Where("id = ?", 1), "UPDATE user SET name = $1 WHERE id = $2"},
+		{pgAgent.DeleteBuilder("user").Where("id = ?", 1), "DELETE FROM user WHERE id = $1"},
+		{mysqlAgent.DeleteBuilder("user").Where("id = ?", 1), "DELETE FROM user WHERE id = ?"},
+	}
+	for _, c := range cases {
+		sqlStr, _, err := c.builder.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, c.sql, sqlStr)
+	}
+
+	sqliteAgent.SetPlaceholderFormat(QuestionNumber)
+	sqlStr, _, err := sqliteAgent.SelectBuilder("*").From("user").Where("id = ? OR id = ?", 1, 2).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM user WHERE id = ?1 OR id = ?2", sqlStr)
+	assert.Equal(t, []string{"id", "id"}, placeholderColumns(sqlStr))
+
+	// global statement builder is not changed
+	sqlStr, _, err = sq.Select("*").From("user").Where("id = ?", 1).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM user WHERE id = ?", sqlStr)
+}
@@ -0,0 +1,145 @@
+package sqlagent
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/RivenZoo/dsncfg"
+)
+
+// DefaultAgentName is the registry name of SqlAgent used by module level functions.
+const DefaultAgentName = "default"
+
+var (
+	errorAgentRegistered = errors.New("sqlagent already registered error")
+	errorEmptyAgentName  = errors.New("empty sqlagent name error")
+)
+
+var (
+	agentsMu sync.RWMutex
+	agents   = make(map[string]*SqlAgent)
+)
+
+// InitNamed create SqlAgent with database config and register it with name.
+// Name DefaultAgentName will also be used by module level functions.
+func InitNamed(name string, cfg *dsncfg.Database) error {
+	return initNamed(name, cfg, nil)
+}
+
+func initNamed(name string, cfg *dsncfg.Database, agentCfg *agentConfig) error {
+	if name == "" {
+		return errorEmptyAgentName
+	}
+	if Get(name) != nil {
+		return errorAgentRegistered
+	}
+	if cfg == nil {
+		return ErrWrongConfig
+	}
+	setDefaultDBParameters(cfg)
+	agent, err := NewSqlAgent(cfg)
+	if err != nil {
+		return err
+	}
+	if err = agentCfg.apply(agent); err != nil {
+		agent.Close()
+		return err
+	}
+	if err = Register(name, agent); err != nil {
+		agent.Close()
+		return err
+	}
+	return nil
+}
+
+// InitNamedFromConfig create SqlAgent with config file and register it with name.
+// cfgFile: config file path, support file type [.json | .yaml/.yml], default decoder is json.
+// Options in config file such as "slow_query" are applied to SqlAgent.
+func InitNamedFromConfig(name, cfgFile string) error {
+	cfg, err := readDBConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	agentCfg, err := readAgentConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	return initNamed(name, cfg, agentCfg)
+}
+
+// InitNamedFromEnv find config file "database-$name.[json | yaml/yml]" and register SqlAgent with name.
+// Search dirs are the same with InitFromEnv.
+func InitNamedFromEnv(name string) error {
+	if name == "" {
+		return errorEmptyAgentName
+	}
+	cfgFile := findDBConfigFile(dbConfigFileName(name), 3, "config")
+	if _, err := os.Stat(cfgFile); cfgFile == "" || os.IsNotExist(err) {
+		return ErrConfigNotFound
+	}
+	return InitNamedFromConfig(name, cfgFile)
+}
+
+// Register add SqlAgent created by caller to registry with name.
+func Register(name string, agent *SqlAgent) error {
+	if name == "" {
+		return errorEmptyAgentName
+	}
+	if agent == nil {
+		return errorWrongArgs
+	}
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+
+	if _, ok := agents[name]; ok {
+		return errorAgentRegistered
+	}
+	agents[name] = agent
+	if name == DefaultAgentName {
+		defaultAgent = agent
+	}
+	return nil
+}
+
+// Get return SqlAgent registered with name, return nil if not found.
+func Get(name string) *SqlAgent {
+	agentsMu.RLock()
+	defer agentsMu.RUnlock()
+	return agents[name]
+}
+
+// Names return sorted names of registered SqlAgent.
+func Names() []string {
+	agentsMu.RLock()
+	defer agentsMu.RUnlock()
+
+	names := make([]string, 0, len(agents))
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CloseAll close all registered SqlAgent and clear registry.
+// Return the first close error.
+func CloseAll() error {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+
+	var firstErr error
+	for name, agent := range agents {
+		if err := agent.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(agents, name)
+	}
+	defaultAgent = nil
+
+	initMu.Lock()
+	initOnce = &sync.Once{}
+	initMu.Unlock()
+	return firstErr
+}
@@ -0,0 +1,77 @@
+package sqlagent
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func unregisterTestAgent(name string) {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+	delete(agents, name)
+	if name == DefaultAgentName {
+		defaultAgent = nil
+	}
+}
+
+func TestRegister(t *testing.T) {
+	orders := &SqlAgent{}
+	users := &SqlAgent{}
+
+	assert.Equal(t, errorEmptyAgentName, Register("", orders))
+	assert.Equal(t, errorWrongArgs, Register("orders", nil))
+
+	assert.Nil(t, Register("orders", orders))
+	defer unregisterTestAgent("orders")
+	assert.Nil(t, Register("users", users))
+	defer unregisterTestAgent("users")
+	assert.Equal(t, errorAgentRegistered, Register("orders", users))
+
+	assert.True(t, Get("orders") == orders)
+	assert.True(t, Get("users") == users)
+	assert.Nil(t, Get("unknown"))
+	assert.Equal(t, []string{"orders", "users"}, Names())
+
+	assert.Nil(t, Register(DefaultAgentName, orders))
+	defer unregisterTestAgent(DefaultAgentName)
+	assert.True(t, defaultAgent == orders)
+}
+
+func TestCloseAll_ConcurrentInit(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Init(nil)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, CloseAll())
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, CloseAll())
+	assert.Equal(t, ErrWrongConfig, Init(nil))
+	assert.Nil(t, CloseAll())
+}
+
+func TestFindNamedDBConfig(t *testing.T) {
+	name := "orders"
+	assert.Equal(t, ErrConfigNotFound, InitNamedFromEnv(name))
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd error: %v", err)
+	}
+	fpath := createTestConfig(filepath.Join(pwd, "config"), dbConfigFileName(name), t)
+	defer rmTestconfig(filepath.Join(pwd, "config"))
+
+	if found := findDBConfigFile(dbConfigFileName(name), 1, "config"); found != fpath {
+		t.Fatalf("findDBConfigFile found %s, expect %s", found, fpath)
+	}
+}
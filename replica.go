@@ -0,0 +1,170 @@
+package sqlagent
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+)
+
+// Replica is a read only database used by SqlAgent to run read query.
+type Replica struct {
+	db       *sqlx.DB
+	inFlight int64
+}
+
+// DB return sqlx.DB of replica.
+func (r *Replica) DB() *sqlx.DB {
+	return r.db
+}
+
+// InFlight return number of running queries on replica.
+func (r *Replica) InFlight() int64 {
+	return atomic.LoadInt64(&r.inFlight)
+}
+
+func (r *Replica) acquire() {
+	atomic.AddInt64(&r.inFlight, 1)
+}
+
+func (r *Replica) release() {
+	atomic.AddInt64(&r.inFlight, -1)
+}
+
+// Balancer choose one replica to run read query.
+// Pick is called concurrently and replicas is never empty.
+type Balancer interface {
+	Pick(replicas []*Replica) *Replica
+}
+
+type roundRobinBalancer struct {
+	next uint64
+}
+
+// NewRoundRobinBalancer return Balancer which pick replicas in turn.
+func NewRoundRobinBalancer() Balancer {
+	return &roundRobinBalancer{}
+}
+
+func (b *roundRobinBalancer) Pick(replicas []*Replica) *Replica {
+	n := atomic.AddUint64(&b.next, 1) - 1
+	return replicas[n%uint64(len(replicas))]
+}
+
+type randomBalancer struct{}
+
+// NewRandomBalancer return Balancer which pick replica randomly.
+func NewRandomBalancer() Balancer {
+	return randomBalancer{}
+}
+
+func (randomBalancer) Pick(replicas []*Replica) *Replica {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+type leastInFlightBalancer struct {
+	roundRobin roundRobinBalancer
+}
+
+// NewLeastInFlightBalancer return Balancer which pick replica with least running queries.
+// Replicas with the same running queries are picked in turn.
+func NewLeastInFlightBalancer() Balancer {
+	return &leastInFlightBalancer{}
+}
+
+func (b *leastInFlightBalancer) Pick(replicas []*Replica) *Replica {
+	start := int(atomic.AddUint64(&b.roundRobin.next, 1) % uint64(len(replicas)))
+	picked := replicas[start]
+	least := picked.InFlight()
+	for i := 1; i < len(replicas); i++ {
+		r := replicas[(start+i)%len(replicas)]
+		if n := r.InFlight(); n < least {
+			picked, least = r, n
+		}
+	}
+	return picked
+}
+
+// NewSqlAgentWithReplicas create SqlAgent with one primary database and replica databases.
+// GetContext and SelectContext run on replica picked by Balancer, default is round robin.
+// ExecContext and Transaction always run on primary.
+// Default mysql parameters of Init are set to primary and replicas,
+// replicas should use the same time zone parameter "loc" as primary.
+func NewSqlAgentWithReplicas(primary *dsncfg.Database, replicas ...*dsncfg.Database) (*SqlAgent, error) {
+	if primary == nil {
+		return nil, ErrWrongConfig
+	}
+	setDefaultDBParameters(primary)
+	agent, err := NewSqlAgent(primary)
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range replicas {
+		if err = agent.addReplica(cfg); err != nil {
+			agent.Close()
+			return nil, err
+		}
+	}
+	agent.balancer = NewRoundRobinBalancer()
+	return agent, nil
+}
+
+// addReplica connect replica database with cfg, cfg is handled the same as primary.
+func (a *SqlAgent) addReplica(cfg *dsncfg.Database) error {
+	if cfg == nil {
+		return ErrWrongConfig
+	}
+	setDefaultDBParameters(cfg)
+	if err := cfg.Init(); err != nil {
+		return err
+	}
+	loc, err := timeLocation(cfg)
+	if err != nil {
+		return err
+	}
+	if (loc == nil) != (a.loc == nil) || loc != nil && loc.String() != a.loc.String() {
+		return ErrWrongConfig
+	}
+	db, err := sqlx.ConnectContext(context.Background(), driverName(cfg), cfg.DSN())
+	if err != nil {
+		return wrapQueryError(cfg.Type, "", err)
+	}
+	db.Mapper = a.db.Mapper
+	a.replicas = append(a.replicas, &Replica{db: db})
+	return nil
+}
+
+// SetBalancer set Balancer used to pick replica.
+func (a *SqlAgent) SetBalancer(b Balancer) {
+	a.balancer = b
+}
+
+// Replicas return replicas of SqlAgent.
+func (a *SqlAgent) Replicas() []*Replica {
+	return a.replicas
+}
+
+type primaryContextKey struct{}
+
+// WithPrimary return context which force read query to run on primary database.
+// Use it for read-your-writes paths.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	force, _ := ctx.Value(primaryContextKey{}).(bool)
+	return force
+}
+
+// readDB return database to run read query and a func to call when query is done.
+func (a *SqlAgent) readDB(ctx context.Context) (*sqlx.DB, func()) {
+	if len(a.replicas) == 0 || a.balancer == nil || usePrimary(ctx) {
+		return a.db, func() {}
+	}
+	r := a.balancer.Pick(a.replicas)
+	r.acquire()
+	return r.db, r.release
+}
@@ -0,0 +1,93 @@
+package sqlagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func testReplicas(n int) []*Replica {
+	replicas := make([]*Replica, n)
+	for i := range replicas {
+		replicas[i] = &Replica{db: &sqlx.DB{}}
+	}
+	return replicas
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	replicas := testReplicas(3)
+	b := NewRoundRobinBalancer()
+	for i := 0; i < 6; i++ {
+		assert.True(t, replicas[i%3] == b.Pick(replicas))
+	}
+}
+
+func TestRandomBalancer(t *testing.T) {
+	replicas := testReplicas(3)
+	b := NewRandomBalancer()
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, replicas, b.Pick(replicas))
+	}
+}
+
+func TestLeastInFlightBalancer(t *testing.T) {
+	replicas := testReplicas(3)
+	replicas[0].acquire()
+	replicas[2].acquire()
+	b := NewLeastInFlightBalancer()
+	for i := 0; i < 3; i++ {
+		assert.True(t, replicas[1] == b.Pick(replicas))
+	}
+	replicas[1].acquire()
+	replicas[1].acquire()
+	assert.True(t, replicas[1] != b.Pick(replicas))
+}
+
+func TestSqlAgent_ReadDB(t *testing.T) {
+	primary := &sqlx.DB{}
+	a := &SqlAgent{db: primary}
+	db, done := a.readDB(context.TODO())
+	done()
+	assert.True(t, primary == db)
+
+	a.replicas = testReplicas(2)
+	a.balancer = NewRoundRobinBalancer()
+	db, done = a.readDB(context.TODO())
+	assert.True(t, a.replicas[0].db == db)
+	assert.Equal(t, int64(1), a.replicas[0].InFlight())
+	done()
+	assert.Equal(t, int64(0), a.replicas[0].InFlight())
+
+	db, done = a.readDB(WithPrimary(context.TODO()))
+	done()
+	assert.True(t, primary == db)
+}
+
+func TestSqlAgent_AddReplica(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	a.loc, _ = time.LoadLocation("Asia/Shanghai")
+	replica := func(params map[string]string) *dsncfg.Database {
+		// nothing listens on port 1
+		return &dsncfg.Database{Type: dsncfg.MySql, Host: "127.0.0.1", Port: 1, Name: "test", User: "test",
+			Parameters: params}
+	}
+
+	cfg := replica(nil)
+	err := a.addReplica(cfg)
+	assert.True(t, errors.Is(err, ErrConnection))
+	var queryErr *QueryError
+	assert.True(t, errors.As(err, &queryErr))
+	assert.Equal(t, "Asia/Shanghai", cfg.Parameters["loc"])
+	assert.Equal(t, "true", cfg.Parameters["parseTime"])
+
+	_, err = time.LoadLocation("Nowhere/City")
+	assert.Equal(t, err, a.addReplica(replica(map[string]string{"loc": "Nowhere/City"})))
+	assert.Equal(t, ErrWrongConfig, a.addReplica(replica(map[string]string{"loc": "UTC"})))
+	assert.Equal(t, ErrWrongConfig, a.addReplica(nil))
+	assert.Equal(t, 0, len(a.Replicas()))
+}
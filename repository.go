@@ -0,0 +1,143 @@
+package sqlagent
+
+import (
+	"context"
+	"reflect"
+
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// Repository provide typed CRUD methods of model T on one table.
+// Table name is got from TableNamer or snake case of type name,
+// primary key is field marked by tag option "pk" (e.g. `db:"id,pk,autoincr"`) or column "id".
+// If model has field marked by tag option "softdelete" (e.g. `db:"deleted_at,softdelete"`),
+// Delete sets the column to current time and selected rows are filtered by `deleted_at IS NULL`,
+// use Unscoped to include soft deleted rows and delete rows permanently.
+type Repository[T any] struct {
+	ex         Executor
+	table      string
+	pk         string
+	columns    []string
+	softDelete string
+	unscoped   bool
+}
+
+// NewRepository create Repository of model T, T should be a struct.
+func NewRepository[T any](ex Executor) (*Repository[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, errorWrongModel
+	}
+	pk := primaryKeyField(ex.Mapper().TypeMap(t))
+	if pk == nil {
+		return nil, errorNoPrimaryKey
+	}
+	repo := &Repository[T]{
+		ex:      ex,
+		table:   modelTableName(t),
+		pk:      pk.Name,
+		columns: ex.ModelColumns(new(T)),
+	}
+	if fi := fieldWithOption(ex.Mapper().TypeMap(t), tagOptionSoftDelete); fi != nil {
+		repo.softDelete = fi.Name
+	}
+	return repo, nil
+}
+
+// WithExecutor return a copy of Repository which run sql with ex, e.g. a transaction.
+func (r *Repository[T]) WithExecutor(ex Executor) *Repository[T] {
+	repo := *r
+	repo.ex = ex
+	return &repo
+}
+
+// Unscoped return a copy of Repository which includes soft deleted rows and deletes rows permanently.
+func (r *Repository[T]) Unscoped() *Repository[T] {
+	repo := *r
+	repo.unscoped = true
+	return &repo
+}
+
+// Table return table name of Repository.
+func (r *Repository[T]) Table() string {
+	return r.table
+}
+
+// PrimaryKey return primary key column of Repository.
+func (r *Repository[T]) PrimaryKey() string {
+	return r.pk
+}
+
+// Create insert model, generated id is set to primary key tagged with "autoincr".
+func (r *Repository[T]) Create(ctx context.Context, model *T) error {
+	_, err := r.ex.InsertModel(ctx, r.table, model)
+	return err
+}
+
+// Get return model by primary key.
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (T, error) {
+	var model T
+	err := r.ex.GetContext(ctx, r.SelectBuilder().Where(sq.Eq{r.pk: id}), &model)
+	return model, err
+}
+
+// Update update all columns of model by primary key except soft delete column.
+// Soft deleted row is not updated unless Unscoped is used.
+// It returns ErrStaleObject if model has version column and is modified by others.
+func (r *Repository[T]) Update(ctx context.Context, model *T) error {
+	builder := r.ex.UpdateBuilder(r.table).Where(sq.Eq{r.pk: r.pkValue(model).Interface()})
+	ignoreColumns := []string{r.pk}
+	if r.softDelete != "" {
+		ignoreColumns = append(ignoreColumns, r.softDelete)
+		if !r.unscoped {
+			builder = builder.Where(sq.Eq{r.softDelete: nil})
+		}
+	}
+	_, err := r.ex.UpdateModel(ctx, builder, model, IgnoreColumns(ignoreColumns...))
+	return err
+}
+
+// Delete delete model by primary key, it is soft deleted if model has soft delete column,
+// which is set to current time of clock set by SetClock.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	var builder sq.Sqlizer = r.ex.DeleteBuilder(r.table).Where(sq.Eq{r.pk: id})
+	if r.softDelete != "" && !r.unscoped {
+		builder = r.ex.UpdateBuilder(r.table).Set(r.softDelete, executorTime(r.ex)).
+			Where(sq.Eq{r.pk: id}).Where(sq.Eq{r.softDelete: nil})
+	}
+	_, err := r.ex.ExecContext(ctx, builder)
+	return err
+}
+
+// List return models match where condition, where is the same as sq.SelectBuilder.Where.
+// All models are returned if where is nil.
+func (r *Repository[T]) List(ctx context.Context, where interface{}, args ...interface{}) ([]T, error) {
+	builder := r.SelectBuilder()
+	if where != nil {
+		builder = builder.Where(where, args...)
+	}
+	return r.Find(ctx, builder)
+}
+
+// Find return models selected by builder, builder is usually created by SelectBuilder.
+func (r *Repository[T]) Find(ctx context.Context, builder sq.SelectBuilder) ([]T, error) {
+	var models []T
+	if err := r.ex.SelectContext(ctx, builder, &models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// SelectBuilder return sq.SelectBuilder which select model columns from table.
+// Soft deleted rows are filtered unless Repository is Unscoped.
+func (r *Repository[T]) SelectBuilder() sq.SelectBuilder {
+	builder := r.ex.SelectBuilder(r.columns...).From(r.table)
+	if r.softDelete != "" && !r.unscoped {
+		builder = builder.Where(sq.Eq{r.softDelete: nil})
+	}
+	return builder
+}
+
+func (r *Repository[T]) pkValue(model *T) reflect.Value {
+	return r.ex.Mapper().FieldByName(reflect.ValueOf(model), r.pk)
+}
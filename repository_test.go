@@ -0,0 +1,173 @@
+package sqlagent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type UserOrder struct {
+	OrderID int64  `db:"order_id,pk"`
+	UID     int64  `db:"uid"`
+	Note    string `db:"note"`
+}
+
+type repoUser struct {
+	ID   int64  `db:"id,pk,autoincr"`
+	Name string `db:"name"`
+}
+
+func (repoUser) TableName() string {
+	return "test_repo_user"
+}
+
+func TestToSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"User":      "user",
+		"UserOrder": "user_order",
+		"HTTPLog":   "http_log",
+		"userID":    "user_id",
+	}
+	for name, expect := range cases {
+		assert.Equal(t, expect, toSnakeCase(name))
+	}
+}
+
+func TestNewRepository(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+
+	orders, err := NewRepository[UserOrder](a)
+	assert.Nil(t, err)
+	assert.Equal(t, "user_order", orders.Table())
+	assert.Equal(t, "order_id", orders.PrimaryKey())
+	sqlStr, _, err := orders.SelectBuilder().ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT order_id, uid, note FROM user_order", sqlStr)
+
+	users, err := NewRepository[repoUser](a)
+	assert.Nil(t, err)
+	assert.Equal(t, "test_repo_user", users.Table())
+	assert.Equal(t, "id", users.PrimaryKey())
+
+	_, err = NewRepository[struct{ Name string }](a)
+	assert.Equal(t, errorNoPrimaryKey, err)
+	_, err = NewRepository[int](a)
+	assert.Equal(t, errorWrongModel, err)
+
+	tx := a.WrapTx(&sqlx.Tx{})
+	assert.True(t, tx == users.WithExecutor(tx).ex)
+	assert.True(t, a == users.ex)
+}
+
+type softDeleteUser struct {
+	ID        int64      `db:"id,pk,autoincr"`
+	Name      string     `db:"name"`
+	DeletedAt *time.Time `db:"deleted_at,softdelete"`
+}
+
+func TestRepository_SoftDelete(t *testing.T) {
+	ex := newFakeExecutor(dsncfg.MySql)
+	ex.rowsAffected = 1
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ex.SetClock(func() time.Time { return now })
+	users, err := NewRepository[softDeleteUser](ex)
+	assert.Nil(t, err)
+
+	sqlStr, _, err := users.SelectBuilder().ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT id, name, deleted_at FROM soft_delete_user WHERE deleted_at IS NULL", sqlStr)
+	sqlStr, _, err = users.Unscoped().SelectBuilder().ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT id, name, deleted_at FROM soft_delete_user", sqlStr)
+
+	assert.Nil(t, users.Delete(context.TODO(), 1))
+	assert.Nil(t, users.Unscoped().Delete(context.TODO(), 1))
+	assert.Equal(t, fakeQuery{
+		SQL:  "UPDATE soft_delete_user SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		Args: []interface{}{now, 1},
+	}, ex.queries[0])
+	assert.Equal(t, "DELETE FROM soft_delete_user WHERE id = ?", ex.queries[1].SQL)
+
+	orders, err := NewRepository[UserOrder](ex)
+	assert.Nil(t, err)
+	assert.Nil(t, orders.Delete(context.TODO(), 2))
+	assert.Equal(t, "DELETE FROM user_order WHERE order_id = ?", ex.queries[2].SQL)
+
+	// soft deleted row is not updated, and update doesn't restore it
+	ex.queries, ex.rowsAffected = nil, 0
+	user := softDeleteUser{ID: 1, Name: "a", DeletedAt: &now}
+	assert.Nil(t, users.Update(context.TODO(), &user))
+	assert.Nil(t, users.Unscoped().Update(context.TODO(), &user))
+	assert.Equal(t, []fakeQuery{
+		{SQL: "UPDATE soft_delete_user SET name = ? WHERE id = ? AND deleted_at IS NULL", Args: []interface{}{"a", int64(1)}},
+		{SQL: "UPDATE soft_delete_user SET name = ? WHERE id = ?", Args: []interface{}{"a", int64(1)}},
+	}, ex.queries)
+}
+
+// customExecutor is Executor implemented outside SqlAgent, it hides unexported methods of SqlAgent.
+type customExecutor struct {
+	Executor
+}
+
+func TestRepository_CustomExecutor(t *testing.T) {
+	ex := newFakeExecutor(dsncfg.MySql)
+	users, err := NewRepository[softDeleteUser](customExecutor{ex})
+	assert.Nil(t, err)
+	before := time.Now()
+	assert.Nil(t, users.Delete(context.TODO(), 1))
+	assert.Equal(t, "UPDATE soft_delete_user SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", ex.queries[0].SQL)
+	assert.False(t, ex.queries[0].Args[0].(time.Time).Before(before))
+}
+
+func TestRepository_CRUD(t *testing.T) {
+	testCfg := dsncfg.Database{
+		Host:     "127.0.0.1",
+		Port:     3306,
+		Name:     "myapp_test",
+		Type:     "mysql",
+		User:     "travis",
+		Password: "",
+	}
+	sa, err := NewSqlAgent(&testCfg)
+	if err != nil {
+		t.Fatalf("NewSqlAgent error: %v", err)
+	}
+	defer sa.Close()
+
+	users, err := NewRepository[repoUser](sa)
+	if err != nil {
+		t.Fatalf("NewRepository error: %v", err)
+	}
+	sa.DB().Exec(`DROP TABLE IF EXISTS ` + users.Table())
+	_, err = sa.DB().Exec(`CREATE TABLE ` + users.Table() + ` (id BIGINT AUTO_INCREMENT PRIMARY KEY,
+name varchar(64) default "" NOT NULL) ENGINE=InnoDB`)
+	if err != nil {
+		t.Fatalf("create table error: %v", err)
+	}
+	defer sa.DB().Exec(`DROP TABLE IF EXISTS ` + users.Table())
+
+	ctx := context.TODO()
+	created := repoUser{Name: "a"}
+	assert.Nil(t, users.Create(ctx, &created))
+	assert.NotEqual(t, int64(0), created.ID)
+	assert.Nil(t, users.Create(ctx, &repoUser{ID: 10, Name: "b"}))
+
+	user, err := users.Get(ctx, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, repoUser{ID: 10, Name: "b"}, user)
+
+	user.Name = "c"
+	assert.Nil(t, users.Update(ctx, &user))
+	list, err := users.List(ctx, "name = ?", "c")
+	assert.Nil(t, err)
+	assert.Equal(t, []repoUser{user}, list)
+
+	assert.Nil(t, users.Delete(ctx, 10))
+	list, err = users.List(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list))
+}
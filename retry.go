@@ -0,0 +1,134 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+
+	pgErrSerializationFailure = "40001"
+	pgErrDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy config retry of TransactionWithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of running transaction, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles on every retry.
+	BaseDelay time.Duration
+	// MaxDelay is the upper limit of delay.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by TransactionWithRetry if policy is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    time.Second,
+}
+
+// backoff return delay with jitter before retry, retry starts from 1.
+// Delay is random in [d/2, d], d is BaseDelay * 2^(retry-1) limited by MaxDelay.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// TransactionWithRetry run fn in transaction and retry the whole transaction
+// if it fails by deadlock, lock wait timeout or serialization failure.
+// Return number of attempts and error of the last attempt.
+// If policy is nil, DefaultRetryPolicy is used.
+func (a *SqlAgent) TransactionWithRetry(ctx context.Context, opt *sql.TxOptions, policy *RetryPolicy,
+	fn func(tx *sqlx.Tx) error) (attempts int, err error) {
+	if policy == nil {
+		policy = &DefaultRetryPolicy
+	}
+	if state := txStateFromContext(ctx); state != nil && state.agent == a {
+		// nested transaction can't be retried alone
+		return 1, a.Transaction(ctx, opt, fn)
+	}
+	return retry(ctx, policy, func(err error) bool {
+		return isRetryableError(a.dbType, err)
+	}, func() error {
+		return a.Transaction(ctx, opt, fn)
+	})
+}
+
+// retry call fn until it succeeds, returns error which is not retryable or reaches max attempts.
+func retry(ctx context.Context, policy *RetryPolicy, retryable func(err error) bool, fn func() error) (attempts int, err error) {
+	for {
+		attempts++
+		err = fn()
+		if err == nil || attempts >= policy.MaxAttempts || !retryable(err) {
+			return
+		}
+		timer := time.NewTimer(policy.backoff(attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// isRetryableError check whether error of database type means transaction can be retried.
+func isRetryableError(dbType string, err error) bool {
+	if err == nil {
+		return false
+	}
+	switch dbType {
+	case dsncfg.MySql:
+		var myErr *mysql.MySQLError
+		if errors.As(err, &myErr) {
+			return myErr.Number == mysqlErrDeadlock || myErr.Number == mysqlErrLockWaitTimeout
+		}
+	case dsncfg.Postgresql:
+		code := pgErrorCode(err)
+		return code == pgErrSerializationFailure || code == pgErrDeadlockDetected
+	case dsncfg.Sqlite:
+		msg := err.Error()
+		return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+	}
+	return false
+}
+
+// pgErrorCode return SQLSTATE code of postgres driver error.
+// Support pgx error with method SQLState and lib/pq error with field Code.
+func pgErrorCode(err error) string {
+	for err != nil {
+		if e, ok := err.(interface{ SQLState() string }); ok {
+			return e.SQLState()
+		}
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() == reflect.Struct {
+			if code := v.FieldByName("Code"); code.IsValid() && code.Kind() == reflect.String {
+				return code.String()
+			}
+		}
+		err = errors.Unwrap(err)
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package sqlagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+type pgStateError string
+
+func (e pgStateError) Error() string {
+	return "pg error " + string(e)
+}
+
+func (e pgStateError) SQLState() string {
+	return string(e)
+}
+
+type pqError struct {
+	Code string
+}
+
+func (e *pqError) Error() string {
+	return "pq error " + e.Code
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		dbType    string
+		err       error
+		retryable bool
+	}{
+		{dsncfg.MySql, &mysql.MySQLError{Number: mysqlErrDeadlock}, true},
+		{dsncfg.MySql, fmt.Errorf("wrap: %w", &mysql.MySQLError{Number: mysqlErrLockWaitTimeout}), true},
+		{dsncfg.MySql, &mysql.MySQLError{Number: 1062}, false},
+		{dsncfg.MySql, errors.New("database is locked"), false},
+		{dsncfg.Postgresql, pgStateError(pgErrSerializationFailure), true},
+		{dsncfg.Postgresql, &pqError{Code: pgErrDeadlockDetected}, true},
+		{dsncfg.Postgresql, &pqError{Code: "23505"}, false},
+		{dsncfg.Sqlite, errors.New("database is locked"), true},
+		{dsncfg.Sqlite, errors.New("UNIQUE constraint failed"), false},
+		{dsncfg.Sqlite, nil, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.retryable, isRetryableError(c.dbType, c.err), "%s %v", c.dbType, c.err)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 35 * time.Millisecond}
+	for i := 0; i < 10; i++ {
+		d := p.backoff(1)
+		assert.True(t, d >= 5*time.Millisecond && d <= 10*time.Millisecond, "%v", d)
+		d = p.backoff(2)
+		assert.True(t, d >= 10*time.Millisecond && d <= 20*time.Millisecond, "%v", d)
+		d = p.backoff(5)
+		assert.True(t, d >= 17*time.Millisecond && d <= 35*time.Millisecond, "%v", d)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: mysqlErrDeadlock}
+	retryable := func(err error) bool {
+		return isRetryableError(dsncfg.MySql, err)
+	}
+	policy := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	calls := 0
+	attempts, err := retry(context.TODO(), policy, retryable, func() error {
+		calls++
+		if calls < 2 {
+			return deadlock
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
+	attempts, err = retry(context.TODO(), policy, retryable, func() error {
+		return deadlock
+	})
+	assert.Equal(t, deadlock, err)
+	assert.Equal(t, 3, attempts)
+
+	other := errors.New("other")
+	attempts, err = retry(context.TODO(), policy, retryable, func() error {
+		return other
+	})
+	assert.Equal(t, other, err)
+	assert.Equal(t, 1, attempts)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	attempts, err = retry(ctx, &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}, retryable, func() error {
+		return deadlock
+	})
+	assert.Equal(t, deadlock, err)
+	assert.Equal(t, 1, attempts)
+}
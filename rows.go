@@ -0,0 +1,131 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// Rows is iterator of query result, it must be closed after use.
+//
+//	rows, err := sa.QueryContext(ctx, builder)
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		var u User
+//		if err := rows.Scan(&u); err != nil {
+//			return err
+//		}
+//	}
+//	return rows.Err()
+type Rows struct {
+	rows *sqlx.Rows
+	done func()
+}
+
+// Next prepare the next row for Scan, it returns false if no more row or error occurs, see Err.
+func (r *Rows) Next() bool {
+	return r.rows.Next()
+}
+
+// Scan scan current row to dest.
+// Struct dest is scanned by Mapper of SqlAgent, other dest such as *int64 is scanned by sql.Rows.Scan.
+func (r *Rows) Scan(dest interface{}) error {
+	if isStructDest(r.rows.Mapper, dest) {
+		return r.rows.StructScan(dest)
+	}
+	return r.rows.Scan(dest)
+}
+
+// Err return error occurs during iteration.
+func (r *Rows) Err() error {
+	return r.rows.Err()
+}
+
+// Close close rows and release database connection, it is safe to call Close multiple times.
+func (r *Rows) Close() error {
+	err := r.rows.Close()
+	if r.done != nil {
+		r.done()
+		r.done = nil
+	}
+	return err
+}
+
+// Rows return underlying sqlx.Rows.
+func (r *Rows) Rows() *sqlx.Rows {
+	return r.rows
+}
+
+// isStructDest return whether dest is pointer to struct mapped to columns.
+func isStructDest(mapper *reflectx.Mapper, dest interface{}) bool {
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr || t.Implements(scannerType) {
+		return false
+	}
+	t = t.Elem()
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	// struct without mapped field such as time.Time is scanned as a column
+	return len(mapper.TypeMap(t).Index) > 0
+}
+
+// QueryContext query records by sql built by sq.SelectBuilder and return iterator of rows.
+// It runs on replica if SqlAgent has replicas, use WithPrimary(ctx) to run on primary.
+// Rows should be closed after use, hooks are called after query is sent.
+// builder: sq.SelectBuilder
+func (a *SqlAgent) QueryContext(ctx context.Context, builder sq.Sqlizer) (*Rows, error) {
+	db, done := a.readDB(ctx)
+	rows, err := a.queryContext(ctx, db, builder, false)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	rows.done = done
+	return rows, nil
+}
+
+func (a *SqlAgent) queryContext(ctx context.Context, queryer sqlx.QueryerContext, builder sq.Sqlizer, inTx bool) (*Rows, error) {
+	var rows *sqlx.Rows
+	err := a.runHooks(ctx, builder, inTx, func(ctx context.Context, event *QueryEvent) (err error) {
+		rows, err = queryer.QueryxContext(ctx, event.SQL, event.Args...)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{rows: rows}, nil
+}
+
+// ForEach query records by sql built by builder and call fn with each row scanned to T.
+// Iteration stops if fn returns error and the error is returned.
+func ForEach[T any](ctx context.Context, ex Executor, builder sq.Sqlizer, fn func(row T) error) error {
+	rows, err := ex.QueryContext(ctx, builder)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row T
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
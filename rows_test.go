@@ -0,0 +1,87 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsStructDest(t *testing.T) {
+	mapper := reflectx.NewMapper("db")
+	var n int64
+	var ts time.Time
+	var ns sql.NullString
+	assert.True(t, isStructDest(mapper, &repoUser{}))
+	assert.False(t, isStructDest(mapper, repoUser{}))
+	assert.False(t, isStructDest(mapper, &n))
+	assert.False(t, isStructDest(mapper, &ts))
+	assert.False(t, isStructDest(mapper, &ns))
+	assert.False(t, isStructDest(mapper, nil))
+}
+
+func TestSqlAgent_QueryContext(t *testing.T) {
+	testCfg := dsncfg.Database{
+		Host:     "127.0.0.1",
+		Port:     3306,
+		Name:     "myapp_test",
+		Type:     "mysql",
+		User:     "travis",
+		Password: "",
+	}
+	sa, err := NewSqlAgent(&testCfg)
+	if err != nil {
+		t.Fatalf("NewSqlAgent error: %v", err)
+	}
+	defer sa.Close()
+
+	users, err := NewRepository[repoUser](sa)
+	if err != nil {
+		t.Fatalf("NewRepository error: %v", err)
+	}
+	sa.DB().Exec(`DROP TABLE IF EXISTS ` + users.Table())
+	_, err = sa.DB().Exec(`CREATE TABLE ` + users.Table() + ` (id BIGINT AUTO_INCREMENT PRIMARY KEY,
+name varchar(64) default "" NOT NULL) ENGINE=InnoDB`)
+	if err != nil {
+		t.Fatalf("create table error: %v", err)
+	}
+	defer sa.DB().Exec(`DROP TABLE IF EXISTS ` + users.Table())
+
+	ctx := context.TODO()
+	_, err = sa.InsertModels(ctx, users.Table(), []repoUser{{Name: "a"}, {Name: "b"}, {Name: "c"}})
+	assert.Nil(t, err)
+
+	rows, err := sa.QueryContext(ctx, users.SelectBuilder().OrderBy("id"))
+	assert.Nil(t, err)
+	var names []string
+	for rows.Next() {
+		var u repoUser
+		assert.Nil(t, rows.Scan(&u))
+		names = append(names, u.Name)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Nil(t, rows.Close())
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	names = nil
+	err = ForEach(ctx, sa, sa.SelectBuilder("name").From(users.Table()).OrderBy("id"), func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	errStop := errors.New("stop")
+	err = sa.Transaction(ctx, nil, func(tx *sqlx.Tx) error {
+		return ForEach(ctx, sa.WrapTx(tx), users.SelectBuilder(), func(u repoUser) error {
+			return errStop
+		})
+	})
+	assert.Equal(t, errStop, err)
+}
@@ -0,0 +1,81 @@
+package sqlagent
+
+import (
+	"context"
+
+	"github.com/lann/builder"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// Count return number of rows selected by builder, see CountBuilder.
+func (a *SqlAgent) Count(ctx context.Context, selectBuilder sq.SelectBuilder) (uint64, error) {
+	return count(ctx, a, selectBuilder)
+}
+
+// Exists return whether builder selects any row, it runs `SELECT EXISTS(... LIMIT 1)`.
+func (a *SqlAgent) Exists(ctx context.Context, selectBuilder sq.SelectBuilder) (bool, error) {
+	return exists(ctx, a, selectBuilder)
+}
+
+// Pluck select one column of rows selected by builder to dest, dest should be pointer to slice such as *[]int64.
+func (a *SqlAgent) Pluck(ctx context.Context, selectBuilder sq.SelectBuilder, column string, dest interface{}) error {
+	return pluck(ctx, a, selectBuilder, column, dest)
+}
+
+// SelectMaps select rows as maps of column name to value, it is useful for ad-hoc reporting query.
+// []byte value is converted to string.
+func (a *SqlAgent) SelectMaps(ctx context.Context, builder sq.Sqlizer) ([]map[string]interface{}, error) {
+	return selectMaps(ctx, a, builder)
+}
+
+func count(ctx context.Context, ex Executor, selectBuilder sq.SelectBuilder) (uint64, error) {
+	var n uint64
+	err := ex.GetContext(ctx, CountBuilder(selectBuilder), &n)
+	return n, err
+}
+
+func exists(ctx context.Context, ex Executor, selectBuilder sq.SelectBuilder) (bool, error) {
+	outer := ex.SelectBuilder()
+	if f, ok := builder.Get(selectBuilder, "PlaceholderFormat"); ok {
+		outer = outer.PlaceholderFormat(f.(sq.PlaceholderFormat))
+	}
+	// placeholders are replaced by outer query
+	sqlStr, args, err := selectBuilder.Limit(1).PlaceholderFormat(sq.Question).ToSql()
+	if err != nil {
+		return false, err
+	}
+	var ok bool
+	err = ex.GetContext(ctx, outer.Column("EXISTS("+sqlStr+")", args...), &ok)
+	return ok, err
+}
+
+func pluck(ctx context.Context, ex Executor, selectBuilder sq.SelectBuilder, column string, dest interface{}) error {
+	selectBuilder = builder.Delete(selectBuilder, "Columns").(sq.SelectBuilder).Columns(column)
+	return ex.SelectContext(ctx, selectBuilder, dest)
+}
+
+func selectMaps(ctx context.Context, ex Executor, builder sq.Sqlizer) ([]map[string]interface{}, error) {
+	rows, err := ex.QueryContext(ctx, builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []map[string]interface{}
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := rows.Rows().MapScan(row); err != nil {
+			return nil, err
+		}
+		for k, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[k] = string(b)
+			}
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, rows.Close()
+}
@@ -0,0 +1,61 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+func TestScalarHelpers(t *testing.T) {
+	ex := newFakeExecutor(dsncfg.Postgresql)
+	ex.selectResult = []int64{1, 2}
+	ctx := context.TODO()
+	builder := ex.SelectBuilder("id", "name").From("user").Where(sq.Eq{"uid": 1}).OrderBy("id")
+
+	ex.getResult = uint64(2)
+	n, err := count(ctx, ex, builder)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), n)
+
+	ex.getResult = true
+	ok, err := exists(ctx, ex, builder)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	var ids []int64
+	assert.Nil(t, pluck(ctx, ex, builder, "id", &ids))
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	expects := []string{
+		"SELECT COUNT(*) FROM user WHERE uid = $1",
+		"SELECT EXISTS(SELECT id, name FROM user WHERE uid = $1 ORDER BY id LIMIT 1)",
+		"SELECT id FROM user WHERE uid = $1 ORDER BY id",
+	}
+	for i, expect := range expects {
+		assert.Equal(t, fakeQuery{SQL: expect, Args: []interface{}{1}}, ex.queries[i])
+	}
+}
+
+func TestSqlAgent_SelectMaps(t *testing.T) {
+	d, db := newFakeDB("mysql")
+	d.columns = []string{"id", "name", "score"}
+	d.rows = [][]driver.Value{{int64(1), []byte("a"), 1.5}, {int64(2), []byte("b"), nil}}
+	a := newSqlAgent(db, dsncfg.MySql)
+	defer a.Close()
+
+	rows, err := a.SelectMaps(context.TODO(), a.SelectBuilder("id", "name", "score").From("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"id": int64(1), "name": "a", "score": 1.5},
+		{"id": int64(2), "name": "b", "score": nil},
+	}, rows)
+
+	d.rows = nil
+	rows, err = a.SelectMaps(context.TODO(), a.SelectBuilder("id").From("user"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(rows))
+}
@@ -0,0 +1,297 @@
+package sqlagent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const redactedArg = "***"
+
+// SlowQueryConfig config slow query log of SqlAgent.
+// It can be set in database config file with key "slow_query", e.g.
+//
+//	"slow_query": {"threshold": "200ms", "redact_columns": ["password", "token"]}
+type SlowQueryConfig struct {
+	// Threshold is duration string parsed by time.ParseDuration.
+	// Query cost more than threshold is logged.
+	Threshold string `json:"threshold" yaml:"threshold"`
+	// RedactColumns are columns whose args are masked in log, case insensitive.
+	RedactColumns []string `json:"redact_columns" yaml:"redact_columns"`
+}
+
+// Logger output log, *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+var defaultLogger Logger = log.New(os.Stderr, "[sqlagent] ", log.LstdFlags)
+
+// SlowQueryLog is a Hook which log sql cost more than threshold.
+type SlowQueryLog struct {
+	threshold time.Duration
+	redact    map[string]bool
+	logger    Logger
+}
+
+// NewSlowQueryLog create SlowQueryLog with threshold, logger and columns to redact.
+// If logger is nil, log to stderr.
+func NewSlowQueryLog(threshold time.Duration, logger Logger, redactColumns ...string) *SlowQueryLog {
+	l := &SlowQueryLog{
+		threshold: threshold,
+		redact:    make(map[string]bool),
+	}
+	for _, col := range redactColumns {
+		l.redact[strings.ToLower(col)] = true
+	}
+	l.SetLogger(logger)
+	return l
+}
+
+// SetLogger set logger of SlowQueryLog, nil means log to stderr.
+func (l *SlowQueryLog) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = defaultLogger
+	}
+	l.logger = logger
+}
+
+func (l *SlowQueryLog) Before(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (l *SlowQueryLog) After(ctx context.Context, event *QueryEvent) {
+	if event.Duration < l.threshold {
+		return
+	}
+	l.logger.Printf("slow query: cost=%v caller=%s sql=%s args=%v err=%v",
+		event.Duration, callerOutside(), event.SQL, l.redactArgs(event.SQL, event.Args), event.Err)
+}
+
+// redactArgs mask args of redact columns, arg whose column is unknown is also masked,
+// such as arg of function call `crypt(?, gen_salt('bf'))`.
+func (l *SlowQueryLog) redactArgs(sqlStr string, args []interface{}) []interface{} {
+	if len(l.redact) == 0 {
+		return args
+	}
+	columns := placeholderColumns(sqlStr)
+	redacted := make([]interface{}, len(args))
+	for i, arg := range args {
+		if i >= len(columns) || columns[i] == "" || l.redact[strings.ToLower(columns[i])] {
+			arg = redactedArg
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+// EnableSlowQueryLog add SlowQueryLog hook to SqlAgent with config.
+// If logger is nil, log to stderr.
+func (a *SqlAgent) EnableSlowQueryLog(cfg *SlowQueryConfig, logger Logger) error {
+	if cfg == nil {
+		return ErrWrongConfig
+	}
+	threshold, err := time.ParseDuration(cfg.Threshold)
+	if err != nil {
+		return err
+	}
+	a.slowLog = NewSlowQueryLog(threshold, logger, cfg.RedactColumns...)
+	a.AddHook(a.slowLog)
+	return nil
+}
+
+// SlowQueryLog return SlowQueryLog enabled by EnableSlowQueryLog, return nil if not enabled.
+func (a *SqlAgent) SlowQueryLog() *SlowQueryLog {
+	return a.slowLog
+}
+
+var packageDir string
+
+func init() {
+	_, file, _, _ := runtime.Caller(0)
+	packageDir = filepath.Dir(file)
+}
+
+// callerOutside return file:line of the first caller outside sqlagent, squirrel and sqlx.
+func callerOutside() string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return "unknown"
+}
+
+func isInternalFrame(frame runtime.Frame) bool {
+	if filepath.Dir(frame.File) == packageDir && !strings.HasSuffix(frame.File, "_test.go") {
+		return true
+	}
+	return strings.Contains(frame.Function, "Masterminds/squirrel") ||
+		strings.Contains(frame.Function, "jmoiron/sqlx")
+}
+
+// placeholderColumns guess column name of each placeholder in sql.
+// Column of INSERT values is taken from column list, others are taken from
+// the identifier before comparison, e.g. "name" of "name = ?" and "id IN (?,?)".
+// Empty string is returned for placeholder whose column is unknown, e.g. argument of function call.
+func placeholderColumns(sqlStr string) []string {
+	tokens := sqlTokens(sqlStr)
+	var columns []string
+
+	var insertColumns []string
+	inValues := false
+	tuplePos := 0
+	depth := 0
+	for i, tok := range tokens {
+		upper := strings.ToUpper(tok)
+		switch {
+		case upper == "INTO" && insertColumns == nil && i+2 < len(tokens) && tokens[i+2] == "(":
+			insertColumns = []string{}
+			for j := i + 3; j < len(tokens) && tokens[j] != ")"; j++ {
+				if tokens[j] != "," {
+					insertColumns = append(insertColumns, unquoteIdent(tokens[j]))
+				}
+			}
+		case upper == "VALUES" && insertColumns != nil:
+			inValues = true
+		case inValues && tok == "(":
+			depth++
+			if depth == 1 {
+				tuplePos = 0
+			}
+		case inValues && tok == ")":
+			depth--
+		case inValues && depth == 1 && tok == ",":
+			tuplePos++
+		case inValues && depth == 0 && tok != ",":
+			inValues = false
+		}
+		if !isPlaceholder(tok) {
+			continue
+		}
+		if inValues && depth > 0 {
+			col := ""
+			if tuplePos < len(insertColumns) {
+				col = insertColumns[tuplePos]
+			}
+			columns = append(columns, col)
+			continue
+		}
+		columns = append(columns, columnBefore(tokens, i))
+	}
+	return columns
+}
+
+var skipBeforePlaceholder = map[string]bool{
+	"(": true, ",": true, "=": true, "<>": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+	"LIKE": true, "IN": true, "NOT": true, "IS": true, "BETWEEN": true, "AND": true,
+}
+
+func columnBefore(tokens []string, i int) string {
+	for j := i - 1; j >= 0; j-- {
+		tok := tokens[j]
+		if isPlaceholder(tok) || skipBeforePlaceholder[strings.ToUpper(tok)] {
+			continue
+		}
+		// identifier followed by "(" is function name, e.g. LOWER(?)
+		if isIdentToken(tok) && (j+1 >= len(tokens) || tokens[j+1] != "(") {
+			return unquoteIdent(tok)
+		}
+		break
+	}
+	return ""
+}
+
+func isPlaceholder(tok string) bool {
+	return tok == "?" || (len(tok) > 1 && (tok[0] == '$' || tok[0] == '?') && unicode.IsDigit(rune(tok[1])))
+}
+
+func isIdentToken(tok string) bool {
+	c := rune(tok[0])
+	return c == '`' || c == '"' || c == '_' || unicode.IsLetter(c)
+}
+
+// unquoteIdent remove quotes and table prefix, e.g. `u`.`name` -> name.
+func unquoteIdent(tok string) string {
+	if i := strings.LastIndex(tok, "."); i >= 0 {
+		tok = tok[i+1:]
+	}
+	return strings.Trim(tok, "`\"")
+}
+
+// sqlTokens split sql into identifiers, placeholders, punctuations and operators.
+// String literals are dropped.
+func sqlTokens(sqlStr string) []string {
+	var tokens []string
+	for i := 0; i < len(sqlStr); {
+		c := sqlStr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'':
+			j := i + 1
+			for j < len(sqlStr) {
+				if sqlStr[j] == '\'' {
+					if j+1 < len(sqlStr) && sqlStr[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			i = j + 1
+		case c == '?':
+			// numbered placeholder ?NNN
+			j := i + 1
+			for j < len(sqlStr) && unicode.IsDigit(rune(sqlStr[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlStr[i:j])
+			i = j
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '<' || c == '>' || c == '=' || c == '!':
+			j := i + 1
+			for j < len(sqlStr) && strings.IndexByte("<>=", sqlStr[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, sqlStr[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(sqlStr) && !isTokenBoundary(sqlStr[j]) {
+				if q := sqlStr[j]; q == '`' || q == '"' {
+					if k := strings.IndexByte(sqlStr[j+1:], q); k >= 0 {
+						j += k + 1
+					}
+				}
+				j++
+			}
+			if j == i {
+				j++
+			}
+			tokens = append(tokens, sqlStr[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+func isTokenBoundary(c byte) bool {
+	return strings.IndexByte(" \t\n\r(),?<>=!'", c) >= 0
+}
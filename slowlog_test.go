@@ -0,0 +1,122 @@
+package sqlagent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+type bufLogger struct {
+	lines []string
+}
+
+func (l *bufLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestPlaceholderColumns(t *testing.T) {
+	cases := []struct {
+		builder sq.Sqlizer
+		columns []string
+	}{
+		{
+			sq.Insert("user").Columns("name", "`password`").Values("a", "b").Values("c", "d"),
+			[]string{"name", "password", "name", "password"},
+		},
+		{
+			sq.Update("user").Set("token", "t").Where(sq.Eq{"u.id": []int{1, 2}}).Where("name LIKE ?", "a%"),
+			[]string{"token", "id", "id", "name"},
+		},
+		{
+			sq.Select("*").From("user").Where("note = 'it''s ?'").Where("age BETWEEN ? AND ?", 1, 2).
+				Where(sq.NotEq{"password": "p"}),
+			[]string{"age", "age", "password"},
+		},
+		{
+			sq.Select("*").From("user").Where("id = ?", 1).PlaceholderFormat(sq.Dollar),
+			[]string{"id"},
+		},
+		{
+			sq.Update("user").Set("password", "p").Set("name", "a").Where("id = ?", 1),
+			[]string{"password", "name", "id"},
+		},
+		{
+			sq.Update("user").Set("password", sq.Expr("crypt(?, gen_salt('bf'))", "p")).
+				Set("token", sq.Expr("COALESCE(?, token)", "t")).Where("name = LOWER(?)", "a"),
+			[]string{"", "", ""},
+		},
+	}
+	for _, c := range cases {
+		sqlStr, _, err := c.builder.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, c.columns, placeholderColumns(sqlStr), sqlStr)
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	logger := &bufLogger{}
+	l := NewSlowQueryLog(100*time.Millisecond, logger, "Password")
+
+	builder := sq.Insert("user").Columns("name", "password").Values("a", "secret")
+	sqlStr, args, _ := builder.ToSql()
+	event := &QueryEvent{Builder: builder, SQL: sqlStr, Args: args, Duration: 50 * time.Millisecond}
+	l.After(context.TODO(), event)
+	assert.Equal(t, 0, len(logger.lines))
+
+	event.Duration = 200 * time.Millisecond
+	l.After(context.TODO(), event)
+	if !assert.Equal(t, 1, len(logger.lines)) {
+		t.FailNow()
+	}
+	line := logger.lines[0]
+	t.Log(line)
+	assert.True(t, strings.Contains(line, "[a ***]"))
+	assert.False(t, strings.Contains(line, "secret"))
+	assert.True(t, strings.Contains(line, "slowlog_test.go:"))
+
+	update := sq.Update("user").Set("password", sq.Expr("crypt(?, gen_salt('bf'))", "secret")).
+		Set("name", "a").Where("token = COALESCE(?, token)", "secret2")
+	sqlStr, args, _ = update.ToSql()
+	assert.Equal(t, []interface{}{"***", "a", "***"}, l.redactArgs(sqlStr, args))
+	assert.Equal(t, args, NewSlowQueryLog(0, logger).redactArgs(sqlStr, args))
+}
+
+func TestReadAgentConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlagent")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	yamlCfg := `host: localhost
+type: mysql
+slow_query:
+  threshold: 200ms
+  redact_columns: [password]
+`
+	fpath := filepath.Join(dir, "database.yaml")
+	if err = ioutil.WriteFile(fpath, []byte(yamlCfg), os.ModePerm); err != nil {
+		t.Fatalf("Create file error: %v", err)
+	}
+	agentCfg, err := readAgentConfig(fpath)
+	assert.Nil(t, err)
+	assert.Equal(t, &SlowQueryConfig{Threshold: "200ms", RedactColumns: []string{"password"}}, agentCfg.SlowQuery)
+
+	a := &SqlAgent{}
+	assert.Nil(t, agentCfg.apply(a))
+	assert.NotNil(t, a.SlowQueryLog())
+	assert.Equal(t, 1, len(a.hooks))
+
+	fpath = createTestConfig(dir, defaultDBConfigFileName, t)
+	agentCfg, err = readAgentConfig(fpath)
+	assert.Nil(t, err)
+	assert.Nil(t, agentCfg.SlowQuery)
+}
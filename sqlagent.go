@@ -13,37 +13,61 @@ import (
 )
 
 var (
-	errorWrongConfig      = errors.New("database config error")
-	errorWrongArgs        = errors.New("func args error")
-	errorNotFoundDBConfig = errors.New("not found database config error")
+	errorWrongArgs = errors.New("func args error")
 )
 
 type SqlAgent struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	dbType   string
+	builder  sq.StatementBuilderType
+	replicas []*Replica
+	balancer Balancer
+	hooks    []Hook
+	slowLog  *SlowQueryLog
+
+	panicHandler   PanicHandler
+	recoverTxPanic bool
+
+	maxPlaceholders int
+
+	now func() time.Time
+	loc *time.Location
+
+	stmtCache *stmtCache
 }
 
 func NewSqlAgent(cfg *dsncfg.Database) (*SqlAgent, error) {
 	if cfg == nil {
-		return nil, errorWrongConfig
+		return nil, ErrWrongConfig
 	}
 	err := cfg.Init()
 	if err != nil {
 		return nil, err
 	}
 	dsn := cfg.DSN()
-	agent := &SqlAgent{}
 
-	db, err := sqlx.ConnectContext(context.Background(), driverName(cfg), dsn)
+	loc, err := timeLocation(cfg)
 	if err != nil {
 		return nil, err
 	}
-	agent.db = db
-	if cfg.Type == dsncfg.Postgresql {
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	db, err := sqlx.ConnectContext(context.Background(), driverName(cfg), dsn)
+	if err != nil {
+		return nil, wrapQueryError(cfg.Type, "", err)
 	}
+	agent := newSqlAgent(db, cfg.Type)
+	agent.loc = loc
 	return agent, nil
 }
 
+func newSqlAgent(db *sqlx.DB, dbType string) *SqlAgent {
+	return &SqlAgent{
+		db:      db,
+		dbType:  dbType,
+		builder: sq.StatementBuilder.PlaceholderFormat(placeholderFormat(dbType)),
+	}
+}
+
 func driverName(cfg *dsncfg.Database) string {
 	switch cfg.Type {
 	case dsncfg.MySql:
@@ -56,53 +80,53 @@ func driverName(cfg *dsncfg.Database) string {
 	return ""
 }
 
+// Close close cached statements, primary database and replicas.
 func (a *SqlAgent) Close() error {
-	return a.db.Close()
+	if a.stmtCache != nil {
+		a.stmtCache.close()
+	}
+	err := a.db.Close()
+	for _, r := range a.replicas {
+		if e := r.db.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
+// DB return sqlx.DB of primary database.
 func (a *SqlAgent) DB() *sqlx.DB {
 	return a.db
 }
 
-func (a *SqlAgent) Transaction(ctx context.Context, opt *sql.TxOptions, fn func(tx *sqlx.Tx) error) error {
-	tx, err := a.db.BeginTxx(ctx, opt)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	err = fn(tx)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
-}
-
 // InsertBuilder return squirrel.InsertBuilder for table into
 // into: insert table name
 func (a *SqlAgent) InsertBuilder(into string) sq.InsertBuilder {
-	return sq.Insert(into)
+	return a.builder.Insert(into)
 }
 
 func (a *SqlAgent) UpdateBuilder(table string) sq.UpdateBuilder {
-	return sq.Update(table)
+	return a.builder.Update(table)
 }
 
 func (a *SqlAgent) DeleteBuilder(table string) sq.DeleteBuilder {
-	return sq.Delete(table)
+	return a.builder.Delete(table)
 }
 
 func (a *SqlAgent) SelectBuilder(columns ...string) sq.SelectBuilder {
-	return sq.Select(columns...)
+	return a.builder.Select(columns...)
 }
 
 // InsertModelBuilder use name and value of model feild to build insert sql.
+// Field tagged with "autoincr" option (e.g. `db:"id,pk,autoincr"`) is skipped if it is zero value.
+// Field tagged with "created" or "updated" option (e.g. `db:"created_at,created"`) is set to current time
+// if it is zero value, see SetClock.
 // ignoreColumns should be the same with column name that is converted by sqlx.DB.Mapper.
 func (a *SqlAgent) InsertModelBuilder(into string, model interface{}, ignoreColumns ...string) sq.InsertBuilder {
 	fieldMap := a.db.Mapper.TypeMap(reflect.TypeOf(model))
 	valueMap := a.db.Mapper.FieldMap(reflect.Indirect(reflect.ValueOf(model)))
 
-	builder := sq.Insert(into)
+	builder := a.builder.Insert(into)
 
 	var params []interface{}
 	var columnNames []string
@@ -113,7 +137,14 @@ func (a *SqlAgent) InsertModelBuilder(into string, model interface{}, ignoreColu
 			continue
 		}
 		if data, ok := valueMap[name]; ok {
+			if isAutoIncrField(v) && data.IsZero() {
+				continue
+			}
 			columnNames = append(columnNames, name)
+			if isTimestampField(v) && data.IsZero() {
+				params = append(params, a.fillTimestamp(data))
+				continue
+			}
 			params = append(params, data.Interface())
 		}
 	}
@@ -125,64 +156,64 @@ func (a *SqlAgent) InsertModelBuilder(into string, model interface{}, ignoreColu
 
 // SetUpdateColumns use name and value of model feild to build update sql.
 // ignoreColumns should be the same with column name that is converted by sqlx.DB.Mapper.
+// Use SetUpdateColumnsWith to update part of columns.
 func (a *SqlAgent) SetUpdateColumns(updateBuilder sq.UpdateBuilder, model interface{}, ignoreColumns ...string) sq.UpdateBuilder {
-	fieldMap := a.db.Mapper.TypeMap(reflect.TypeOf(model))
-	valueMap := a.db.Mapper.FieldMap(reflect.Indirect(reflect.ValueOf(model)))
-	clauses := make(map[string]interface{})
-
-	for _, v := range fieldMap.Index {
-		name := v.Name
-		if isIgnoreFields(name, ignoreColumns) {
-			continue
-		}
-		if data, ok := valueMap[name]; ok {
-			clauses[name] = data.Interface()
-		}
-	}
-	return updateBuilder.SetMap(clauses)
+	return a.SetUpdateColumnsWith(updateBuilder, model, IgnoreColumns(ignoreColumns...))
 }
 
 // ExecContext exec sql built by sq.InsertBuilder/sq.UpdateBuilder/sq.DeleteBuilder and return result.
 // builder: sq.InsertBuilder, sq.UpdateBuilder or sq.DeleteBuilder
 func (a *SqlAgent) ExecContext(ctx context.Context, builder sq.Sqlizer) (sql.Result, error) {
-	sqlStr, args, err := builder.ToSql()
-	if err != nil {
-		return nil, err
-	}
-	return a.db.ExecContext(ctx, sqlStr, args...)
+	return a.execContext(ctx, a.db, builder, false)
 }
 
 // GetContext get one record by sql built by sq.SelectBuilder and scan to dest.
+// It returns ErrNotFound if no record is found.
+// Error is *QueryError which wraps sql.ErrNoRows if no record is found,
+// so check it by errors.Is(err, ErrNotFound) or errors.Is(err, sql.ErrNoRows) instead of err == sql.ErrNoRows.
+// It runs on replica if SqlAgent has replicas, use WithPrimary(ctx) to run on primary.
 // builder: sq.SelectBuilder
 func (a *SqlAgent) GetContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
-	sqlStr, args, err := builder.ToSql()
-	if err != nil {
-		return err
-	}
-	return a.db.GetContext(ctx, dest, sqlStr, args...)
+	db, done := a.readDB(ctx)
+	defer done()
+	return a.getContext(ctx, db, builder, dest, false)
 }
 
 // SelectContext get one or multi records by sql built by sq.SelectBuilder and scan to dest.
+// It runs on replica if SqlAgent has replicas, use WithPrimary(ctx) to run on primary.
 // builder: sq.SelectBuilder
 func (a *SqlAgent) SelectContext(ctx context.Context, builder sq.Sqlizer, dest interface{}) error {
-	sqlStr, args, err := builder.ToSql()
-	if err != nil {
-		return err
-	}
-	return a.db.SelectContext(ctx, dest, sqlStr, args...)
+	db, done := a.readDB(ctx)
+	defer done()
+	return a.selectContext(ctx, db, builder, dest, false)
 }
 
 // SetDBMapper set mapper to sqlx.DB.Mapper.
 // Default mapper use tag `db`, if no tags it will use lower case field name as column name.
 func (a *SqlAgent) SetDBMapper(mapper *reflectx.Mapper) {
 	a.db.Mapper = mapper
+	for _, r := range a.replicas {
+		r.db.Mapper = mapper
+	}
+}
+
+// Mapper return mapper of sqlx.DB which maps model field to column.
+func (a *SqlAgent) Mapper() *reflectx.Mapper {
+	return a.db.Mapper
 }
 
-// SetConnectionConfig set connection config to sql.DB.
+// SetConnectionConfig set connection config to sql.DB of primary and replicas.
 func (a *SqlAgent) SetConnectionConfig(cfg dsncfg.ConnectionConfig) {
-	a.db.SetMaxOpenConns(cfg.MaxOpenConnections)
-	a.db.SetMaxIdleConns(cfg.MaxIdleConnections)
-	a.db.SetConnMaxLifetime(time.Duration(cfg.MaxLifeTime) * time.Second)
+	setConnectionConfig(a.db, cfg)
+	for _, r := range a.replicas {
+		setConnectionConfig(r.db, cfg)
+	}
+}
+
+func setConnectionConfig(db *sqlx.DB, cfg dsncfg.ConnectionConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConnections)
+	db.SetMaxIdleConns(cfg.MaxIdleConnections)
+	db.SetConnMaxLifetime(time.Duration(cfg.MaxLifeTime) * time.Second)
 }
 
 // ModelColumns use sqlx.DB.Mapper to extract model table columns name.
@@ -206,33 +237,33 @@ func (a *SqlAgent) ModelColumns(model interface{}, ignoreColumns ...string) []st
 }
 
 // TxExecContext exec sql built by sq.InsertBuilder/sq.UpdateBuilder/sq.DeleteBuilder and return result.
+// Hooks of SqlAgent are called if tx is begun by SqlAgent.Transaction.
 // builder: sq.InsertBuilder, sq.UpdateBuilder or sq.DeleteBuilder
 func TxExecContext(ctx context.Context, tx *sqlx.Tx, builder sq.Sqlizer) (sql.Result, error) {
-	sqlStr, args, err := builder.ToSql()
-	if err != nil {
-		return nil, err
-	}
-	return tx.ExecContext(ctx, sqlStr, args...)
+	return txAgent(tx).execContext(ctx, tx, builder, true)
 }
 
 // TxGetContext get one record by sql built by sq.SelectBuilder and scan to dest.
+// Hooks of SqlAgent are called if tx is begun by SqlAgent.Transaction.
+// Error is *QueryError which wraps sql.ErrNoRows if no record is found,
+// so check it by errors.Is(err, ErrNotFound) or errors.Is(err, sql.ErrNoRows) instead of err == sql.ErrNoRows.
 // builder: sq.SelectBuilder
 func TxGetContext(ctx context.Context, tx *sqlx.Tx, builder sq.Sqlizer, dest interface{}) error {
-	sqlStr, args, err := builder.ToSql()
-	if err != nil {
-		return err
-	}
-	return tx.GetContext(ctx, dest, sqlStr, args...)
+	return txAgent(tx).getContext(ctx, tx, builder, dest, true)
 }
 
 // TxSelectContext get one or multi records by sql built by sq.SelectBuilder and scan to dest.
+// Hooks of SqlAgent are called if tx is begun by SqlAgent.Transaction.
 // builder: sq.SelectBuilder
 func TxSelectContext(ctx context.Context, tx *sqlx.Tx, builder sq.Sqlizer, dest interface{}) error {
-	sqlStr, args, err := builder.ToSql()
-	if err != nil {
-		return err
-	}
-	return tx.SelectContext(ctx, dest, sqlStr, args...)
+	return txAgent(tx).selectContext(ctx, tx, builder, dest, true)
+}
+
+// TxQueryContext query records by sql built by sq.SelectBuilder and return iterator of rows.
+// Hooks of SqlAgent are called if tx is begun by SqlAgent.Transaction.
+// builder: sq.SelectBuilder
+func TxQueryContext(ctx context.Context, tx *sqlx.Tx, builder sq.Sqlizer) (*Rows, error) {
+	return txAgent(tx).queryContext(ctx, tx, builder, true)
 }
 
 func isIgnoreFields(name string, ignore []string) bool {
@@ -0,0 +1,181 @@
+package sqlagent
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CacheStats is statistics of prepared statement cache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Len is number of cached statements.
+	Len int
+}
+
+// stmtCacheKey is key of prepared statement, statement is prepared on primary or replica db.
+type stmtCacheKey struct {
+	db    *sqlx.DB
+	query string
+}
+
+// stmtCacheEntry is cached statement, it is closed when it is evicted and not in use.
+type stmtCacheEntry struct {
+	key  stmtCacheKey
+	stmt *sqlx.Stmt
+	// refs is number of callers using stmt.
+	refs    int
+	evicted bool
+}
+
+// stmtCache is LRU cache of prepared statements, evicted statements are closed.
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[stmtCacheKey]*list.Element
+	stats CacheStats
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[stmtCacheKey]*list.Element),
+	}
+}
+
+// get return prepared statement of query on db, prepare and cache it if not cached.
+// Entry is referenced by caller until release is called, so it is not closed in use.
+func (c *stmtCache) get(ctx context.Context, db *sqlx.DB, query string) (*stmtCacheEntry, error) {
+	key := stmtCacheKey{db: db, query: query}
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		c.stats.Hits++
+		entry := e.Value.(*stmtCacheEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// prepare without lock, statement prepared by others meanwhile is used
+	stmt, err := db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		stmt.Close()
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*stmtCacheEntry)
+		entry.refs++
+		return entry, nil
+	}
+	entry := &stmtCacheEntry{key: key, stmt: stmt, refs: 1}
+	c.items[key] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+	return entry, nil
+}
+
+// release drop reference of entry got by get, evicted entry is closed if it is not in use.
+func (c *stmtCache) release(entry *stmtCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// removeElement remove statement from cache, it is closed now if it is not in use, otherwise closed by release.
+func (c *stmtCache) removeElement(e *list.Element) {
+	entry := c.ll.Remove(e).(*stmtCacheEntry)
+	delete(c.items, entry.key)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// close close all cached statements.
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *stmtCache) statistics() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len = c.ll.Len()
+	return stats
+}
+
+// EnableStmtCache cache at most size prepared statements keyed by sql,
+// so ExecContext, GetContext and SelectContext don't prepare the same sql again.
+// The least recently used statement is evicted if cache is full, and closed after running calls release it.
+// Statement runs in transaction by tx.StmtxContext. size <= 0 disables cache and closes cached statements.
+// It should be called before SqlAgent is used.
+func (a *SqlAgent) EnableStmtCache(size int) {
+	if a.stmtCache != nil {
+		a.stmtCache.close()
+		a.stmtCache = nil
+	}
+	if size > 0 {
+		a.stmtCache = newStmtCache(size)
+	}
+}
+
+// StmtCacheStats return statistics of prepared statement cache, it is zero if cache is disabled.
+func (a *SqlAgent) StmtCacheStats() CacheStats {
+	if a == nil || a.stmtCache == nil {
+		return CacheStats{}
+	}
+	return a.stmtCache.statistics()
+}
+
+// cachedStmt return cached statement of query for runner which is *sqlx.DB or *sqlx.Tx.
+// It returns nil statement if cache is disabled or runner is not supported.
+// release should be called after statement is used.
+func (a *SqlAgent) cachedStmt(ctx context.Context, runner interface{}, query string) (stmt *sqlx.Stmt, release func(), err error) {
+	if a == nil || a.stmtCache == nil {
+		return nil, nil, nil
+	}
+	cache := a.stmtCache
+	switch r := runner.(type) {
+	case *sqlx.DB:
+		entry, err := cache.get(ctx, r, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		s := *entry.stmt
+		s.Mapper = r.Mapper
+		return &s, func() { cache.release(entry) }, nil
+	case *sqlx.Tx:
+		entry, err := cache.get(ctx, a.db, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		txStmt := r.StmtxContext(ctx, entry.stmt)
+		return txStmt, func() {
+			txStmt.Close()
+			cache.release(entry)
+		}, nil
+	}
+	return nil, nil, nil
+}
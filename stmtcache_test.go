@@ -0,0 +1,76 @@
+package sqlagent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqlAgent_StmtCache(t *testing.T) {
+	d, db := newFakeDB("mysql")
+	db.SetMaxOpenConns(1)
+	a := newSqlAgent(db, dsncfg.MySql)
+	a.EnableStmtCache(2)
+	defer a.Close()
+	ctx := context.TODO()
+
+	exec := func(table string) {
+		_, err := a.ExecContext(ctx, a.DeleteBuilder(table))
+		assert.Nil(t, err)
+	}
+	exec("a")
+	exec("a")
+	exec("b")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Len: 2}, a.StmtCacheStats())
+	assert.Equal(t, int64(2), atomic.LoadInt64(&d.prepared))
+
+	exec("c")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Len: 2}, a.StmtCacheStats())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&d.closed))
+
+	err := a.Transaction(ctx, nil, func(tx *sqlx.Tx) error {
+		_, err := a.WrapTx(tx).ExecContext(ctx, a.DeleteBuilder("c"))
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), a.StmtCacheStats().Hits)
+
+	a.EnableStmtCache(0)
+	assert.Equal(t, CacheStats{}, a.StmtCacheStats())
+	assert.Equal(t, atomic.LoadInt64(&d.prepared), atomic.LoadInt64(&d.closed))
+}
+
+func TestSqlAgent_StmtCacheConcurrent(t *testing.T) {
+	d, db := newFakeDB("mysql")
+	a := newSqlAgent(db, dsncfg.MySql)
+	a.EnableStmtCache(1)
+	defer a.Close()
+	ctx := context.TODO()
+
+	var wg sync.WaitGroup
+	var errCount int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				// tables are evicted by each other in cache of size 1
+				if _, err := a.ExecContext(ctx, a.DeleteBuilder(fmt.Sprintf("t%d", (i+j)%3))); err != nil {
+					atomic.AddInt64(&errCount, 1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(0), atomic.LoadInt64(&errCount))
+	assert.True(t, a.StmtCacheStats().Evictions > 0)
+
+	a.EnableStmtCache(0)
+	assert.Equal(t, atomic.LoadInt64(&d.prepared), atomic.LoadInt64(&d.closed))
+}
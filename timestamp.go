@@ -0,0 +1,87 @@
+package sqlagent
+
+import (
+	"database/sql"
+	"reflect"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	timePtrType  = reflect.TypeOf(&time.Time{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// SetClock set clock which returns time of created, updated and soft delete time columns, nil means time.Now.
+// It is useful to fix time in tests.
+func (a *SqlAgent) SetClock(now func() time.Time) {
+	a.now = now
+}
+
+// SetTimeLocation set time zone of created and updated time columns, nil means time zone of clock.
+// NewSqlAgent uses mysql parameter "loc" of cfg, no time zone is set if it is absent.
+// Init and InitNamed family set "loc" to Asia/Shanghai by default.
+func (a *SqlAgent) SetTimeLocation(loc *time.Location) {
+	a.loc = loc
+}
+
+// timeLocation return time zone of database parameter "loc" of mysql.
+func timeLocation(cfg *dsncfg.Database) (*time.Location, error) {
+	if cfg.Type != dsncfg.MySql {
+		return nil, nil
+	}
+	name, ok := cfg.Parameters["loc"]
+	if !ok {
+		return nil, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// clocker is implemented by *SqlAgent and *Tx to get current time set by SetClock and SetTimeLocation.
+type clocker interface {
+	timestamp() time.Time
+}
+
+// executorTime return current time of ex, it is time.Now if ex is not implemented by SqlAgent.
+func executorTime(ex Executor) time.Time {
+	if c, ok := ex.(clocker); ok {
+		return c.timestamp()
+	}
+	return time.Now()
+}
+
+// timestamp return current time of created and updated time columns.
+func (a *SqlAgent) timestamp() time.Time {
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+	t := now()
+	if a.loc != nil {
+		t = t.In(a.loc)
+	}
+	return t
+}
+
+// fillTimestamp set current time to time column field if field is settable, and return the time value.
+// field should be time.Time, *time.Time or sql.NullTime, otherwise field value is returned.
+func (a *SqlAgent) fillTimestamp(field reflect.Value) interface{} {
+	t := a.timestamp()
+	var v reflect.Value
+	switch field.Type() {
+	case timeType:
+		v = reflect.ValueOf(t)
+	case timePtrType:
+		v = reflect.ValueOf(&t)
+	case nullTimeType:
+		v = reflect.ValueOf(sql.NullTime{Time: t, Valid: true})
+	default:
+		return field.Interface()
+	}
+	if field.CanSet() {
+		field.Set(v)
+	}
+	return v.Interface()
+}
@@ -0,0 +1,65 @@
+package sqlagent
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type timestampModel struct {
+	ID        int64        `db:"id,pk,autoincr"`
+	Name      string       `db:"name"`
+	CreatedAt time.Time    `db:"created_at,created"`
+	UpdatedAt sql.NullTime `db:"updated_at,updated"`
+}
+
+func TestSqlAgent_Timestamp(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	loc, err := timeLocation(&dsncfg.Database{Type: dsncfg.MySql, Parameters: map[string]string{"loc": "Asia/Shanghai"}})
+	assert.Nil(t, err)
+	a.SetTimeLocation(loc)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a.SetClock(func() time.Time { return now })
+	expect := now.In(loc)
+
+	m := &timestampModel{Name: "a"}
+	sqlStr, args, err := a.InsertModelBuilder("user", m).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (name,created_at,updated_at) VALUES (?,?,?)", sqlStr)
+	assert.Equal(t, []interface{}{"a", expect, sql.NullTime{Time: expect, Valid: true}}, args)
+	assert.Equal(t, expect, m.CreatedAt)
+	assert.Equal(t, "Asia/Shanghai", m.UpdatedAt.Time.Location().String())
+
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, loc)
+	m = &timestampModel{ID: 1, Name: "b", CreatedAt: created}
+	builder := a.SetUpdateColumnsWith(a.UpdateBuilder("user"), m, OnlyColumns("name"))
+	sqlStr, args, err = builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE user SET name = ?, updated_at = ?", sqlStr)
+	assert.Equal(t, []interface{}{"b", sql.NullTime{Time: expect, Valid: true}}, args)
+	assert.Equal(t, created, m.CreatedAt)
+
+	upsertBuilder, err := a.UpsertModelBuilder("user", m, []string{"id"}, nil)
+	assert.Nil(t, err)
+	sqlStr, _, err = upsertBuilder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (id,name,created_at,updated_at) VALUES (?,?,?,?) "+
+		"ON DUPLICATE KEY UPDATE name = VALUES(name), updated_at = VALUES(updated_at)", sqlStr)
+
+	models := []timestampModel{{Name: "c"}}
+	_, err = a.InsertModelsBuilders("user", models)
+	assert.Nil(t, err)
+	assert.Equal(t, expect, models[0].CreatedAt)
+}
+
+func TestTimeLocation(t *testing.T) {
+	loc, err := timeLocation(&dsncfg.Database{Type: dsncfg.Postgresql})
+	assert.Nil(t, err)
+	assert.Nil(t, loc)
+	_, err = timeLocation(&dsncfg.Database{Type: dsncfg.MySql, Parameters: map[string]string{"loc": "Nowhere/City"}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,195 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	errorSavepointUnsupported = errors.New("savepoint unsupported by driver error")
+	errorTxNotFound           = errors.New("transaction not begun by sqlagent error")
+)
+
+// txState is state of transaction begun by SqlAgent.
+type txState struct {
+	agent *SqlAgent
+	tx    *sqlx.Tx
+	// savepoints is number of savepoints created, used to name savepoint.
+	savepoints int
+
+	afterCommit   []func()
+	afterRollback []func()
+}
+
+type txContextKey struct{}
+
+func contextWithTxState(ctx context.Context, state *txState) context.Context {
+	return context.WithValue(ctx, txContextKey{}, state)
+}
+
+func txStateFromContext(ctx context.Context) *txState {
+	state, _ := ctx.Value(txContextKey{}).(*txState)
+	return state
+}
+
+// TxFromContext return transaction carried by context passed to TransactionContext func.
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	if state := txStateFromContext(ctx); state != nil {
+		return state.tx, true
+	}
+	return nil, false
+}
+
+// txStates map *sqlx.Tx to its state, so that Tx helpers can find SqlAgent which begins it.
+var txStates sync.Map
+
+func bindTxState(state *txState) {
+	txStates.Store(state.tx, state)
+}
+
+func unbindTxState(state *txState) {
+	txStates.Delete(state.tx)
+}
+
+func boundTxState(tx *sqlx.Tx) *txState {
+	if state, ok := txStates.Load(tx); ok {
+		return state.(*txState)
+	}
+	return nil
+}
+
+// txAgent return SqlAgent which begins tx, return nil if tx is not begun by SqlAgent.
+func txAgent(tx *sqlx.Tx) *SqlAgent {
+	if state := boundTxState(tx); state != nil {
+		return state.agent
+	}
+	return nil
+}
+
+// AfterCommit register fn to be called after transaction tx is committed.
+// Callbacks are called in registration order, panic in callback is recovered and reported by PanicHandler.
+// Callbacks registered in a savepoint which is rolled back are discarded.
+// tx must be begun by SqlAgent.Transaction or SqlAgent.TransactionContext.
+func AfterCommit(tx *sqlx.Tx, fn func()) error {
+	state := boundTxState(tx)
+	if state == nil {
+		return errorTxNotFound
+	}
+	state.afterCommit = append(state.afterCommit, fn)
+	return nil
+}
+
+// AfterRollback register fn to be called after transaction tx is rolled back.
+// Callbacks are called in registration order, panic in callback is recovered and reported by PanicHandler.
+// Callbacks registered in a savepoint are called after the savepoint is rolled back.
+// tx must be begun by SqlAgent.Transaction or SqlAgent.TransactionContext.
+func AfterRollback(tx *sqlx.Tx, fn func()) error {
+	state := boundTxState(tx)
+	if state == nil {
+		return errorTxNotFound
+	}
+	state.afterRollback = append(state.afterRollback, fn)
+	return nil
+}
+
+func (a *SqlAgent) Transaction(ctx context.Context, opt *sql.TxOptions, fn func(tx *sqlx.Tx) error) error {
+	return a.TransactionContext(ctx, opt, func(ctx context.Context, tx *sqlx.Tx) error {
+		return fn(tx)
+	})
+}
+
+// TransactionContext run fn in transaction, ctx passed to fn carries the transaction.
+// Callbacks registered by AfterCommit/AfterRollback are called after transaction finishes.
+// Panic in fn rolls back transaction, see SetTxPanicRecovery.
+// If ctx already carries transaction of SqlAgent, fn runs in a savepoint of it:
+// savepoint is rolled back if fn returns error and released if fn succeeds.
+// opt is ignored for savepoint.
+func (a *SqlAgent) TransactionContext(ctx context.Context, opt *sql.TxOptions,
+	fn func(ctx context.Context, tx *sqlx.Tx) error) (err error) {
+	if state := txStateFromContext(ctx); state != nil && state.agent == a {
+		return a.savepoint(ctx, state, fn)
+	}
+
+	start := time.Now()
+	tx, err := a.db.BeginTxx(ctx, opt)
+	if err != nil {
+		return wrapQueryError(a.dbType, "", err)
+	}
+	state := &txState{agent: a, tx: tx}
+	bindTxState(state)
+	committed := false
+	defer func() {
+		unbindTxState(state)
+		if committed {
+			a.runCallbacks(state.afterCommit)
+		} else {
+			tx.Rollback()
+			a.runCallbacks(state.afterRollback)
+		}
+		a.afterTx(ctx, start, committed, err)
+	}()
+	err = a.callTxFunc(contextWithTxState(ctx, state), tx, fn)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return wrapQueryError(a.dbType, "", err)
+	}
+	committed = true
+	return nil
+}
+
+func (a *SqlAgent) savepoint(ctx context.Context, state *txState, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	state.savepoints++
+	stmts, err := savepointStatements(a.db.DriverName(), fmt.Sprintf("sqlagent_sp_%d", state.savepoints))
+	if err != nil {
+		return err
+	}
+	if _, err = state.tx.ExecContext(ctx, stmts.save); err != nil {
+		return err
+	}
+	commitCallbacks, rollbackCallbacks := len(state.afterCommit), len(state.afterRollback)
+	if err = a.callTxFunc(ctx, state.tx, fn); err != nil {
+		if _, rollbackErr := state.tx.ExecContext(ctx, stmts.rollback); rollbackErr != nil {
+			return fmt.Errorf("%w, rollback savepoint error: %v", err, rollbackErr)
+		}
+		state.afterCommit = state.afterCommit[:commitCallbacks]
+		a.runCallbacks(state.afterRollback[rollbackCallbacks:])
+		state.afterRollback = state.afterRollback[:rollbackCallbacks]
+		return err
+	}
+	_, err = state.tx.ExecContext(ctx, stmts.release)
+	return err
+}
+
+type savepointStmts struct {
+	save     string
+	rollback string
+	release  string
+}
+
+// savepointStatements return savepoint sql of driver.
+func savepointStatements(driver, name string) (savepointStmts, error) {
+	switch driver {
+	case "mysql", "postgres":
+		return savepointStmts{
+			save:     "SAVEPOINT " + name,
+			rollback: "ROLLBACK TO SAVEPOINT " + name,
+			release:  "RELEASE SAVEPOINT " + name,
+		}, nil
+	case "sqlite3":
+		return savepointStmts{
+			save:     "SAVEPOINT " + name,
+			rollback: "ROLLBACK TO " + name,
+			release:  "RELEASE " + name,
+		}, nil
+	}
+	return savepointStmts{}, errorSavepointUnsupported
+}
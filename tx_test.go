@@ -0,0 +1,139 @@
+package sqlagent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+func TestTxState(t *testing.T) {
+	tx := &sqlx.Tx{}
+	a := &SqlAgent{}
+	state := &txState{agent: a, tx: tx}
+	assert.Nil(t, txAgent(tx))
+
+	bindTxState(state)
+	assert.True(t, a == txAgent(tx))
+	unbindTxState(state)
+	assert.Nil(t, txAgent(tx))
+
+	// nil SqlAgent runs without hooks
+	_, err := txAgent(tx).execContext(context.TODO(), &fakeExecer{}, sq.Delete("user"), true)
+	assert.Nil(t, err)
+
+	_, ok := TxFromContext(context.TODO())
+	assert.False(t, ok)
+	ctxTx, ok := TxFromContext(contextWithTxState(context.TODO(), state))
+	assert.True(t, ok)
+	assert.True(t, tx == ctxTx)
+}
+
+func TestSavepointStatements(t *testing.T) {
+	for _, driver := range []string{"mysql", "postgres"} {
+		stmts, err := savepointStatements(driver, "sp_1")
+		assert.Nil(t, err)
+		assert.Equal(t, savepointStmts{
+			save:     "SAVEPOINT sp_1",
+			rollback: "ROLLBACK TO SAVEPOINT sp_1",
+			release:  "RELEASE SAVEPOINT sp_1",
+		}, stmts)
+	}
+	stmts, err := savepointStatements("sqlite3", "sp_1")
+	assert.Nil(t, err)
+	assert.Equal(t, savepointStmts{
+		save:     "SAVEPOINT sp_1",
+		rollback: "ROLLBACK TO sp_1",
+		release:  "RELEASE sp_1",
+	}, stmts)
+
+	_, err = savepointStatements("", "sp_1")
+	assert.Equal(t, errorSavepointUnsupported, err)
+}
+
+func TestSqlAgent_NestedTransaction(t *testing.T) {
+	testCfg := dsncfg.Database{
+		Host:     "127.0.0.1",
+		Port:     3306,
+		Name:     "myapp_test",
+		Type:     "mysql",
+		User:     "travis",
+		Password: "",
+	}
+	sa, err := NewSqlAgent(&testCfg)
+	if err != nil {
+		t.Fatalf("NewSqlAgent error: %v", err)
+	}
+	defer sa.Close()
+
+	table := "test_nested_tx"
+	sa.DB().Exec(`DROP TABLE IF EXISTS ` + table)
+	_, err = sa.DB().Exec(`CREATE TABLE ` + table + ` (id BIGINT PRIMARY KEY) ENGINE=InnoDB`)
+	if err != nil {
+		t.Fatalf("create table error: %v", err)
+	}
+	defer sa.DB().Exec(`DROP TABLE IF EXISTS ` + table)
+
+	innerErr := errors.New("inner error")
+	var callbacks []string
+	err = sa.TransactionContext(context.TODO(), nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		AfterCommit(tx, func() { callbacks = append(callbacks, "commit 1") })
+		if _, err := TxExecContext(ctx, tx, sa.InsertBuilder(table).Columns("id").Values(1)); err != nil {
+			return err
+		}
+		err := sa.TransactionContext(ctx, nil, func(ctx context.Context, innerTx *sqlx.Tx) error {
+			assert.True(t, tx == innerTx)
+			AfterCommit(innerTx, func() { callbacks = append(callbacks, "commit 2") })
+			AfterRollback(innerTx, func() { callbacks = append(callbacks, "rollback 2") })
+			if _, err := TxExecContext(ctx, innerTx, sa.InsertBuilder(table).Columns("id").Values(2)); err != nil {
+				return err
+			}
+			return innerErr
+		})
+		assert.Equal(t, innerErr, err)
+		AfterCommit(tx, func() { callbacks = append(callbacks, "commit 3") })
+		return sa.TransactionContext(ctx, nil, func(ctx context.Context, innerTx *sqlx.Tx) error {
+			_, err := TxExecContext(ctx, innerTx, sa.InsertBuilder(table).Columns("id").Values(3))
+			return err
+		})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"rollback 2", "commit 1", "commit 3"}, callbacks)
+
+	var ids []int64
+	err = sa.SelectContext(context.TODO(), sa.SelectBuilder("id").From(table).OrderBy("id"), &ids)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 3}, ids)
+}
+
+func TestSqlAgent_SavepointRollbackError(t *testing.T) {
+	d, db := newFakeDB("mysql")
+	rollbackErr := errors.New("rollback savepoint failed")
+	d.execErr = func(query string) error {
+		if strings.HasPrefix(query, "ROLLBACK TO SAVEPOINT") {
+			return rollbackErr
+		}
+		return nil
+	}
+	sa := newSqlAgent(db, dsncfg.MySql)
+	defer sa.Close()
+
+	fnErr := &QueryError{SQL: "DELETE FROM t", Err: errors.New("fn failed")}
+	err := sa.TransactionContext(context.TODO(), nil, func(ctx context.Context, tx *sqlx.Tx) error {
+		return sa.TransactionContext(ctx, nil, func(ctx context.Context, tx *sqlx.Tx) error {
+			return fnErr
+		})
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), rollbackErr.Error())
+	var queryErr *QueryError
+	assert.True(t, errors.As(err, &queryErr))
+	assert.True(t, queryErr == fnErr)
+	assert.Equal(t, int64(1), d.rollbacks)
+	assert.Equal(t, int64(0), d.commits)
+}
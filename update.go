@@ -0,0 +1,205 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// updateOptions decide which columns of model are set by SetUpdateColumnsWith.
+type updateOptions struct {
+	ignoreColumns []string
+	onlyColumns   []string
+	nonZero       bool
+	snapshot      interface{}
+}
+
+// UpdateOption is option of SetUpdateColumnsWith.
+type UpdateOption func(opts *updateOptions)
+
+// IgnoreColumns skip columns, the same as ignoreColumns of SetUpdateColumns.
+func IgnoreColumns(columns ...string) UpdateOption {
+	return func(opts *updateOptions) {
+		opts.ignoreColumns = append(opts.ignoreColumns, columns...)
+	}
+}
+
+// OnlyColumns set only columns in whitelist.
+func OnlyColumns(columns ...string) UpdateOption {
+	return func(opts *updateOptions) {
+		opts.onlyColumns = append(opts.onlyColumns, columns...)
+	}
+}
+
+// OnlyNonZero set only fields which are not zero value.
+// Pointer field which is not nil and sql.Null* field which is valid are set even if they hold zero value,
+// so they can be used to set column to zero value explicitly.
+func OnlyNonZero() UpdateOption {
+	return func(opts *updateOptions) {
+		opts.nonZero = true
+	}
+}
+
+// OnlyChanged set only fields which differ from snapshot, snapshot is model loaded before modified.
+// snapshot should be the same type as model or pointer to it.
+func OnlyChanged(snapshot interface{}) UpdateOption {
+	return func(opts *updateOptions) {
+		opts.snapshot = snapshot
+	}
+}
+
+// SetUpdateColumnsWith use name and value of model feild to build update sql, columns are chosen by opts.
+// Options are combined, e.g. OnlyChanged with IgnoreColumns set changed fields except ignored columns.
+// Column tagged with "version" option (e.g. `db:"version,version"`) is used for optimistic locking
+// unless it is in IgnoreColumns: it adds `WHERE version = ?` and `SET version = version + 1`.
+// Column tagged with "created" option is not updated, column tagged with "updated" option is set to current time.
+// Version and updated time columns are set only if any other column is set.
+// If opts leave no column to set, sql of the builder fails to build, UpdateModel skips such update.
+func (a *SqlAgent) SetUpdateColumnsWith(updateBuilder sq.UpdateBuilder, model interface{}, opts ...UpdateOption) sq.UpdateBuilder {
+	updateBuilder, _ = a.setUpdateColumns(updateBuilder, model, opts...)
+	return updateBuilder
+}
+
+// setUpdateColumns set columns the same as SetUpdateColumnsWith,
+// and report whether any column is set except version and updated time columns.
+func (a *SqlAgent) setUpdateColumns(updateBuilder sq.UpdateBuilder, model interface{},
+	opts ...UpdateOption) (sq.UpdateBuilder, bool) {
+	var o updateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	fieldMap := a.db.Mapper.TypeMap(reflect.TypeOf(model))
+	value := reflect.Indirect(reflect.ValueOf(model))
+	var snapshot reflect.Value
+	if o.snapshot != nil {
+		snapshot = reflect.Indirect(reflect.ValueOf(o.snapshot))
+	}
+	clauses := make(map[string]interface{})
+	changed := false
+	// version and updated time columns are set only if other column is set
+	var versionFields, updatedFields []*reflectx.FieldInfo
+
+	for _, v := range fieldMap.Index {
+		name := v.Name
+		if !isColumnField(v) || isIgnoreFields(name, o.ignoreColumns) {
+			continue
+		}
+		if len(o.onlyColumns) > 0 && !isIgnoreFields(name, o.onlyColumns) && !isVersionField(v) &&
+			!hasTagOption(v, tagOptionUpdated) {
+			continue
+		}
+		data, ok := fieldByIndexes(value, v.Index)
+		if !ok {
+			continue
+		}
+		if hasTagOption(v, tagOptionCreated) {
+			continue
+		}
+		if hasTagOption(v, tagOptionUpdated) {
+			updatedFields = append(updatedFields, v)
+			continue
+		}
+		if isVersionField(v) {
+			versionFields = append(versionFields, v)
+			continue
+		}
+		if o.nonZero && data.IsZero() {
+			continue
+		}
+		if snapshot.IsValid() {
+			if old, ok := fieldByIndexes(snapshot, v.Index); ok && reflect.DeepEqual(old.Interface(), data.Interface()) {
+				continue
+			}
+		}
+		clauses[name] = data.Interface()
+		changed = true
+	}
+	if !changed {
+		return updateBuilder.SetMap(clauses), false
+	}
+	for _, v := range updatedFields {
+		data, _ := fieldByIndexes(value, v.Index)
+		clauses[v.Name] = a.fillTimestamp(data)
+	}
+	for _, v := range versionFields {
+		data, _ := fieldByIndexes(value, v.Index)
+		updateBuilder = updateBuilder.Where(sq.Eq{v.Name: data.Interface()})
+		clauses[v.Name] = sq.Expr(v.Name + " + 1")
+	}
+	return updateBuilder.SetMap(clauses), true
+}
+
+// UpdateModel update model by sql built by SetUpdateColumnsWith.
+// If model has version column, it returns ErrStaleObject when no row is updated
+// and increases version of model after update.
+// model should be pointer to struct to increase version.
+// If opts leave no column to set except version and updated time columns, e.g. OnlyChanged with unchanged model,
+// no sql is run and result with zero rows affected is returned.
+func (a *SqlAgent) UpdateModel(ctx context.Context, updateBuilder sq.UpdateBuilder, model interface{},
+	opts ...UpdateOption) (sql.Result, error) {
+	return a.updateModel(ctx, a.db, updateBuilder, model, false, opts...)
+}
+
+func (a *SqlAgent) updateModel(ctx context.Context, ext sqlx.ExecerContext, updateBuilder sq.UpdateBuilder,
+	model interface{}, inTx bool, opts ...UpdateOption) (sql.Result, error) {
+	updateBuilder, changed := a.setUpdateColumns(updateBuilder, model, opts...)
+	if !changed {
+		return driver.RowsAffected(0), nil
+	}
+	res, err := a.execContext(ctx, ext, updateBuilder, inTx)
+	if err != nil {
+		return nil, err
+	}
+
+	var o updateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	v := reflect.ValueOf(model)
+	fi := fieldWithOption(a.db.Mapper.TypeMap(v.Type()), tagOptionVersion)
+	if fi == nil || isIgnoreFields(fi.Name, o.ignoreColumns) {
+		return res, nil
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return res, err
+	}
+	if n == 0 {
+		return res, ErrStaleObject
+	}
+	if v.Kind() == reflect.Ptr {
+		field := reflectx.FieldByIndexes(v.Elem(), fi.Index)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(field.Int() + 1)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(field.Uint() + 1)
+		default:
+			err = errorWrongVersionKey
+		}
+	}
+	return res, err
+}
+
+// fieldByIndexes return field of v by indexes without allocating nil pointer,
+// return false if field is in nil embedded pointer.
+func fieldByIndexes(v reflect.Value, indexes []int) (reflect.Value, bool) {
+	for i, idx := range indexes {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
@@ -0,0 +1,127 @@
+package sqlagent
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+type partialModel struct {
+	ID    int64         `db:"id"`
+	Name  string        `db:"name"`
+	Age   *int          `db:"age"`
+	Score sql.NullInt64 `db:"score"`
+}
+
+func TestSqlAgent_SetUpdateColumnsWith(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	update := func(model interface{}, opts ...UpdateOption) (string, []interface{}) {
+		builder := a.UpdateBuilder("user").Where(sq.Eq{"id": 1})
+		sqlStr, args, err := a.SetUpdateColumnsWith(builder, model, opts...).ToSql()
+		assert.Nil(t, err)
+		return sqlStr, args
+	}
+
+	zero := 0
+	sqlStr, args := update(&partialModel{Age: &zero, Score: sql.NullInt64{Valid: true}}, OnlyNonZero())
+	assert.Equal(t, "UPDATE user SET age = ?, score = ? WHERE id = ?", sqlStr)
+	assert.Equal(t, []interface{}{&zero, sql.NullInt64{Valid: true}, 1}, args)
+
+	sqlStr, _ = update(&partialModel{ID: 1, Name: "a"}, OnlyNonZero(), IgnoreColumns("id"))
+	assert.Equal(t, "UPDATE user SET name = ? WHERE id = ?", sqlStr)
+
+	sqlStr, _ = update(&partialModel{Name: "a"}, OnlyColumns("name", "score"))
+	assert.Equal(t, "UPDATE user SET name = ?, score = ? WHERE id = ?", sqlStr)
+
+	age := 10
+	snapshot := partialModel{ID: 1, Name: "a", Age: &age}
+	model := snapshot
+	newAge := 10
+	model.Age = &newAge
+	model.Score = sql.NullInt64{Int64: 0, Valid: true}
+	sqlStr, args = update(&model, OnlyChanged(&snapshot))
+	assert.Equal(t, "UPDATE user SET score = ? WHERE id = ?", sqlStr)
+	assert.Equal(t, []interface{}{sql.NullInt64{Valid: true}, 1}, args)
+
+	sqlStr, _ = update(&partialModel{}, IgnoreColumns("id", "age"))
+	assert.Equal(t, "UPDATE user SET name = ?, score = ? WHERE id = ?", sqlStr)
+}
+
+type versionModel struct {
+	ID      int64  `db:"id"`
+	Name    string `db:"name"`
+	Version int    `db:"version,version"`
+}
+
+func TestSqlAgent_SetUpdateColumnsVersion(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	m := &versionModel{ID: 1, Name: "a", Version: 3}
+
+	builder := a.SetUpdateColumns(a.UpdateBuilder("user").Where(sq.Eq{"id": 1}), m, "id")
+	sqlStr, args, err := builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE user SET name = ?, version = version + 1 WHERE id = ? AND version = ?", sqlStr)
+	assert.Equal(t, []interface{}{"a", 1, 3}, args)
+
+	builder = a.SetUpdateColumnsWith(a.UpdateBuilder("user"), m, OnlyColumns("name"))
+	sqlStr, _, err = builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE user SET name = ?, version = version + 1 WHERE version = ?", sqlStr)
+
+	builder = a.SetUpdateColumns(a.UpdateBuilder("user"), m, "id", "version")
+	sqlStr, _, err = builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE user SET name = ?", sqlStr)
+}
+
+func TestSqlAgent_UpdateModel(t *testing.T) {
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	m := &versionModel{ID: 1, Name: "a", Version: 3}
+	builder := a.UpdateBuilder("user").Where(sq.Eq{"id": 1})
+
+	_, err := a.updateModel(context.TODO(), &fakeExecer{}, builder, m, false, IgnoreColumns("id"))
+	assert.Equal(t, ErrStaleObject, err)
+	assert.Equal(t, 3, m.Version)
+
+	_, err = a.updateModel(context.TODO(), &fakeExecer{rowsAffected: 1}, builder, m, false, IgnoreColumns("id"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, m.Version)
+
+	_, err = a.updateModel(context.TODO(), &fakeExecer{}, builder, &partialModel{}, false)
+	assert.Nil(t, err)
+}
+
+func TestSqlAgent_UpdateModelUnchanged(t *testing.T) {
+	type model struct {
+		ID        int64     `db:"id"`
+		Name      string    `db:"name"`
+		Version   int       `db:"version,version"`
+		UpdatedAt time.Time `db:"updated_at,updated"`
+	}
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	m := &model{ID: 1, Name: "a", Version: 3}
+	snapshot := *m
+	builder := a.UpdateBuilder("user").Where(sq.Eq{"id": 1})
+
+	execer := &fakeExecer{rowsAffected: 1}
+	res, err := a.updateModel(context.TODO(), execer, builder, m, false, OnlyChanged(&snapshot), IgnoreColumns("id"))
+	assert.Nil(t, err)
+	n, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Nil(t, execer.ctx)
+	assert.Equal(t, 3, m.Version)
+	assert.True(t, m.UpdatedAt.IsZero())
+
+	m.Name = "b"
+	_, err = a.updateModel(context.TODO(), execer, builder, m, false, OnlyChanged(&snapshot), IgnoreColumns("id"))
+	assert.Nil(t, err)
+	assert.NotNil(t, execer.ctx)
+	assert.Equal(t, 4, m.Version)
+}
@@ -0,0 +1,92 @@
+package sqlagent
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/lann/builder"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// UpsertModelBuilder use name and value of model feild to build insert or update sql.
+// Columns of model are inserted as InsertModelBuilder, and updated on conflict of conflictColumns
+// except conflictColumns, insertOnlyColumns and created time column, the same as SetUpdateColumns.
+// Version column is increased by one instead of being overwritten by version of model.
+// ignoreColumns are neither inserted nor updated, such as generated columns and columns with database default.
+// It builds `ON DUPLICATE KEY UPDATE` for mysql and `ON CONFLICT (...) DO UPDATE SET` for postgres and sqlite,
+// conflictColumns is not used by mysql which detects conflict by primary key and unique index.
+// If no column is updated, conflicting row is kept unchanged: postgres and sqlite use `DO NOTHING`,
+// mysql updates conflictColumns[0] or primary key of model to itself.
+// It returns errorWrongArgs if conflictColumns is empty for postgres and sqlite,
+// and errorNoPrimaryKey if mysql has no column to update, no conflictColumns and model has no primary key.
+// Column names should be the same with column name that is converted by sqlx.DB.Mapper.
+func (a *SqlAgent) UpsertModelBuilder(into string, model interface{}, conflictColumns, insertOnlyColumns []string,
+	ignoreColumns ...string) (sq.InsertBuilder, error) {
+	insertBuilder := a.InsertModelBuilder(into, model, ignoreColumns...)
+	if len(conflictColumns) == 0 && a.dbType != dsncfg.MySql {
+		return insertBuilder, errorWrongArgs
+	}
+
+	fieldMap := a.db.Mapper.TypeMap(reflect.TypeOf(model))
+	var updateColumns []string
+	versionColumn := ""
+	if columns, ok := builder.Get(insertBuilder, "Columns"); ok {
+		for _, name := range columns.([]string) {
+			if isIgnoreFields(name, conflictColumns) || isIgnoreFields(name, insertOnlyColumns) {
+				continue
+			}
+			fi := fieldMap.GetByPath(name)
+			if fi != nil && hasTagOption(fi, tagOptionCreated) {
+				continue
+			}
+			if fi != nil && isVersionField(fi) {
+				versionColumn = name
+				continue
+			}
+			updateColumns = append(updateColumns, name)
+		}
+	}
+	if a.dbType == dsncfg.MySql && len(conflictColumns) == 0 && len(updateColumns) == 0 {
+		// keep row unchanged by updating primary key to itself
+		pk := primaryKeyField(fieldMap)
+		if pk == nil {
+			return insertBuilder, errorNoPrimaryKey
+		}
+		conflictColumns = []string{pk.Name}
+	}
+	return insertBuilder.Suffix(upsertClause(a.dbType, into, conflictColumns, updateColumns, versionColumn)), nil
+}
+
+// upsertClause return conflict clause of dbType which updates updateColumns of table.
+// versionColumn of existing row is increased by one if any column is updated, it is skipped if empty.
+// conflictColumns must not be empty for postgres and sqlite,
+// and updateColumns or conflictColumns must not be empty for mysql.
+func upsertClause(dbType, table string, conflictColumns, updateColumns []string, versionColumn string) string {
+	sets := make([]string, 0, len(updateColumns)+1)
+	if dbType == dsncfg.MySql {
+		for _, name := range updateColumns {
+			sets = append(sets, name+" = VALUES("+name+")")
+		}
+		if len(sets) > 0 && versionColumn != "" {
+			sets = append(sets, versionColumn+" = "+versionColumn+" + 1")
+		}
+		if len(sets) == 0 {
+			// no column to update, keep row unchanged
+			sets = append(sets, conflictColumns[0]+" = "+conflictColumns[0])
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+
+	clause := "ON CONFLICT (" + strings.Join(conflictColumns, ", ") + ")"
+	if len(updateColumns) == 0 {
+		return clause + " DO NOTHING"
+	}
+	for _, name := range updateColumns {
+		sets = append(sets, name+" = EXCLUDED."+name)
+	}
+	if versionColumn != "" {
+		sets = append(sets, versionColumn+" = "+table+"."+versionColumn+" + 1")
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
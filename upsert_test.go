@@ -0,0 +1,120 @@
+package sqlagent
+
+import (
+	"testing"
+
+	"github.com/RivenZoo/dsncfg"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqlAgent_UpsertModelBuilder(t *testing.T) {
+	type model struct {
+		ID        int64  `db:"id,pk,autoincr"`
+		Name      string `db:"name"`
+		Email     string `db:"email"`
+		CreatedAt int64  `db:"created_at"`
+	}
+	m := &model{ID: 1, Name: "a", Email: "a@b.c", CreatedAt: 100}
+
+	cases := []struct {
+		agent  *SqlAgent
+		expect string
+	}{
+		{newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql),
+			"INSERT INTO user (id,name,email,created_at) VALUES (?,?,?,?) " +
+				"ON DUPLICATE KEY UPDATE name = VALUES(name), email = VALUES(email)"},
+		{newSqlAgent(sqlx.NewDb(nil, "postgres"), dsncfg.Postgresql),
+			"INSERT INTO user (id,name,email,created_at) VALUES ($1,$2,$3,$4) " +
+				"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, email = EXCLUDED.email"},
+		{newSqlAgent(sqlx.NewDb(nil, "sqlite3"), dsncfg.Sqlite),
+			"INSERT INTO user (id,name,email,created_at) VALUES (?,?,?,?) " +
+				"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, email = EXCLUDED.email"},
+	}
+	for _, c := range cases {
+		builder, err := c.agent.UpsertModelBuilder("user", m, []string{"id"}, []string{"created_at"})
+		assert.Nil(t, err)
+		sqlStr, args, err := builder.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, c.expect, sqlStr)
+		assert.Equal(t, []interface{}{int64(1), "a", "a@b.c", int64(100)}, args)
+	}
+
+	for _, a := range []*SqlAgent{newSqlAgent(sqlx.NewDb(nil, "postgres"), dsncfg.Postgresql),
+		newSqlAgent(sqlx.NewDb(nil, "sqlite3"), dsncfg.Sqlite)} {
+		_, err := a.UpsertModelBuilder("user", m, nil, nil)
+		assert.Equal(t, errorWrongArgs, err)
+	}
+
+	a := newSqlAgent(sqlx.NewDb(nil, "postgres"), dsncfg.Postgresql)
+	builder, err := a.UpsertModelBuilder("user", m, []string{"id"}, nil, "email", "created_at")
+	assert.Nil(t, err)
+	sqlStr, args, err := builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (id,name) VALUES ($1,$2) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name", sqlStr)
+	assert.Equal(t, []interface{}{int64(1), "a"}, args)
+
+	a = newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	builder, err = a.UpsertModelBuilder("user", m, nil, []string{"id", "name", "email", "created_at"})
+	assert.Nil(t, err)
+	sqlStr, _, err = builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (id,name,email,created_at) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE id = id", sqlStr)
+	builder, err = a.UpsertModelBuilder("user", m, []string{"email"}, []string{"id", "name", "created_at"})
+	assert.Nil(t, err)
+	sqlStr, _, err = builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (id,name,email,created_at) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE email = email", sqlStr)
+
+	type noKey struct {
+		Name string `db:"name"`
+	}
+	_, err = a.UpsertModelBuilder("user", &noKey{Name: "a"}, nil, []string{"name"})
+	assert.Equal(t, errorNoPrimaryKey, err)
+}
+
+func TestSqlAgent_UpsertModelBuilderVersion(t *testing.T) {
+	type model struct {
+		ID      int64  `db:"id,pk"`
+		Name    string `db:"name"`
+		Version int64  `db:"version,version"`
+	}
+	m := &model{ID: 1, Name: "a", Version: 3}
+
+	cases := []struct {
+		agent  *SqlAgent
+		expect string
+	}{
+		{newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql),
+			"INSERT INTO user (id,name,version) VALUES (?,?,?) " +
+				"ON DUPLICATE KEY UPDATE name = VALUES(name), version = version + 1"},
+		{newSqlAgent(sqlx.NewDb(nil, "postgres"), dsncfg.Postgresql),
+			"INSERT INTO user (id,name,version) VALUES ($1,$2,$3) " +
+				"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, version = user.version + 1"},
+		{newSqlAgent(sqlx.NewDb(nil, "sqlite3"), dsncfg.Sqlite),
+			"INSERT INTO user (id,name,version) VALUES (?,?,?) " +
+				"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, version = user.version + 1"},
+	}
+	for _, c := range cases {
+		builder, err := c.agent.UpsertModelBuilder("user", m, []string{"id"}, nil)
+		assert.Nil(t, err)
+		sqlStr, args, err := builder.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, c.expect, sqlStr)
+		assert.Equal(t, []interface{}{int64(1), "a", int64(3)}, args)
+	}
+
+	// version is not increased if no column is updated
+	a := newSqlAgent(sqlx.NewDb(nil, "mysql"), dsncfg.MySql)
+	builder, err := a.UpsertModelBuilder("user", m, []string{"id"}, []string{"name"})
+	assert.Nil(t, err)
+	sqlStr, _, err := builder.ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user (id,name,version) VALUES (?,?,?) ON DUPLICATE KEY UPDATE id = id", sqlStr)
+}
+
+func TestUpsertClause(t *testing.T) {
+	assert.Equal(t, "ON DUPLICATE KEY UPDATE id = id", upsertClause(dsncfg.MySql, "user", []string{"id"}, nil, ""))
+	assert.Equal(t, "ON CONFLICT (uid, name) DO NOTHING",
+		upsertClause(dsncfg.Postgresql, "user", []string{"uid", "name"}, nil, "version"))
+}